@@ -1,27 +1,33 @@
 package v1
 
 import (
+	"github.com/billyboar/battleships/config"
 	"github.com/billyboar/battleships/models/db"
 	"github.com/gorilla/mux"
 )
 
-// APIServer contains db connection and api routes
+// APIServer contains event store and api routes
 type APIServer struct {
 	Router *mux.Router
-	Store  *db.Store
+	Store  db.EventStore
+	Config *config.Config
+	// Archive holds expired sessions, nil when archival is disabled
+	Archive *db.Archive
 }
 
-// NewAPIServer creates new server struct with redis connection
-func NewAPIServer() (*APIServer, error) {
-	store, err := db.NewStore()
-	if err != nil {
-		return nil, err
-	}
-
-	return &APIServer{
+// NewAPIServer creates new server struct backed by given event store
+func NewAPIServer(store db.EventStore, conf *config.Config) *APIServer {
+	server := &APIServer{
 		Router: mux.NewRouter(),
 		Store:  store,
-	}, nil
+		Config: conf,
+	}
+
+	if conf.ArchiveDir != "" {
+		server.Archive = db.NewArchive(conf.ArchiveDir)
+	}
+
+	return server
 }
 
 // RegisterRoutes adds new routes to main routes handler
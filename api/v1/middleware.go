@@ -4,9 +4,11 @@ import (
 	"context"
 	"net/http"
 
-	"github.com/billyboar/battleships/helpers"
+	"github.com/gofrs/uuid"
 
+	"github.com/billyboar/battleships/helpers"
 	"github.com/billyboar/battleships/models"
+	"github.com/billyboar/battleships/models/db"
 )
 
 type contextKey string
@@ -19,13 +21,20 @@ const (
 func (api *APIServer) LoadSessionToCtx(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		sessionID := r.URL.Query().Get("session_id")
-		events, err := api.Store.GetEvents(sessionID)
-		if err != nil {
-			helpers.RenderError(w, "cannot get session events", err, http.StatusBadRequest)
+		if err := validateSessionID(sessionID); err != nil {
+			helpers.RenderError(w, "session_id must be a UUID", err, http.StatusBadRequest)
 			return
 		}
 
-		session, err := models.BuildSessionEvents(events, sessionID)
+		session, err := db.LoadSession(api.Store, sessionID, api.Config)
+		if err == models.ErrSessionNotFound {
+			helpers.RenderError(w, "session not found", err, http.StatusNotFound)
+			return
+		}
+		if replayErr, ok := err.(*models.ReplayError); ok {
+			helpers.RenderError(w, "session history is damaged", replayErr, http.StatusUnprocessableEntity)
+			return
+		}
 		if err != nil {
 			helpers.RenderError(w, "cannot build session", err, http.StatusInternalServerError)
 			return
@@ -37,6 +46,13 @@ func (api *APIServer) LoadSessionToCtx(next http.Handler) http.Handler {
 	})
 }
 
+// validateSessionID rejects IDs which are not UUIDs, stores join
+// session IDs into file paths
+func validateSessionID(sessionID string) error {
+	_, err := uuid.FromString(sessionID)
+	return err
+}
+
 func (api *APIServer) GlobalCORSMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == "OPTIONS" {
@@ -46,7 +62,7 @@ func (api *APIServer) GlobalCORSMiddleware(next http.Handler) http.Handler {
 			headers.Add("Vary", "Access-Control-Request-Method")
 			headers.Add("Vary", "Access-Control-Request-Headers")
 			headers.Add("Access-Control-Allow-Headers", "Content-Type, Origin, Accept, token")
-			headers.Add("Access-Control-Allow-Methods", "GET, POST,OPTIONS")
+			headers.Add("Access-Control-Allow-Methods", "GET, POST, PUT, OPTIONS")
 			w.WriteHeader(http.StatusOK)
 			return
 		}
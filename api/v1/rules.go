@@ -0,0 +1,14 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/billyboar/battleships/helpers"
+	"github.com/billyboar/battleships/models"
+)
+
+// ListRuleSets returns built-in rule sets which can be chosen
+// when creating session
+func (s *APIServer) ListRuleSets(w http.ResponseWriter, r *http.Request) {
+	helpers.RenderJSON(w, models.RuleSets(), http.StatusOK)
+}
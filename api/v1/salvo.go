@@ -0,0 +1,88 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/billyboar/battleships/helpers"
+	"github.com/billyboar/battleships/models"
+)
+
+type SalvoResult struct {
+	Shots     []models.Cell        `json:"shots"` // fired cells, is_dead marks hits
+	DeadShips []*models.BattleShip `json:"dead_ships"`
+}
+
+type SalvoResponse struct {
+	Salvo SalvoResult `json:"salvo"`
+	// ComputerSalvo is empty when player's salvo ends the game
+	ComputerSalvo *SalvoResult         `json:"computer_salvo,omitempty"`
+	Status        models.SessionStatus `json:"status"`
+	Winner        string               `json:"winner,omitempty"`
+}
+
+// shootSalvo handles turn of a salvo game, player fires all the shots
+// at once and computer answers with salvo of its own
+func (s *APIServer) shootSalvo(w http.ResponseWriter, session *models.Session, shots []models.Cell) {
+	results, deadShips, err := session.ShootSalvo(shots, false)
+	if err != nil {
+		renderMoveError(w, err)
+		return
+	}
+
+	// all events of the turn are committed together at the end
+	events := salvoEvents(session.ID, results, deadShips, false)
+	response := SalvoResponse{
+		Salvo: SalvoResult{Shots: results, DeadShips: deadShips},
+	}
+
+	finishEvent, err := session.FinishIfFleetDestroyed()
+	if err != nil {
+		helpers.RenderError(w, "cannot finish session", err, http.StatusInternalServerError)
+		return
+	}
+
+	// computer only answers while its fleet is afloat
+	if finishEvent == nil {
+		computerShots := session.ComputerSalvo()
+		results, deadShips, err = session.ShootSalvo(computerShots, true)
+		if err != nil {
+			helpers.RenderError(w, "computer made invalid move", err, http.StatusInternalServerError)
+			return
+		}
+		events = append(events, salvoEvents(session.ID, results, deadShips, true)...)
+		response.ComputerSalvo = &SalvoResult{Shots: results, DeadShips: deadShips}
+
+		if finishEvent, err = session.FinishIfFleetDestroyed(); err != nil {
+			helpers.RenderError(w, "cannot finish session", err, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if finishEvent != nil {
+		events = append(events, finishEvent)
+	}
+	response.Status = session.Status
+	response.Winner = session.Winner()
+
+	if err := s.Store.AppendEvents(session.ID, session.Version, events...); err != nil {
+		renderAppendError(w, err)
+		return
+	}
+
+	helpers.RenderJSON(w, response, http.StatusOK)
+}
+
+// salvoEvents records salvo of one side followed by ships it sunk
+func salvoEvents(sessionID string, shots []models.Cell, deadShips []*models.BattleShip, isComputer bool) []*models.Event {
+	cells := make([]models.Cell, len(shots))
+	for i, shot := range shots {
+		cells[i] = models.Cell{X: shot.X, Y: shot.Y}
+	}
+
+	events := []*models.Event{models.CreateSalvoEvent(sessionID, cells, isComputer)}
+	for _, deadShip := range deadShips {
+		// destroyed ship belongs to the side being shot at
+		events = append(events, models.CreateDestroyShipEvent(sessionID, deadShip.ID, !isComputer))
+	}
+	return events
+}
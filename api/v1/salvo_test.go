@@ -0,0 +1,69 @@
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/billyboar/battleships/models"
+)
+
+func TestSalvoGame(t *testing.T) {
+	server := newTestServer()
+
+	rec := doRequest(server, "POST", "/api/v1/session", `{"rules": "quick", "salvo": true}`)
+	var created SessionResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatal("cannot decode session:", err)
+	}
+	url := "/api/v1/session/shoot?session_id=" + created.ID
+
+	rec = doRequest(server, "POST", url, `{"cells": [{"x": 0, "y": 0}]}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected %d for salvo of wrong size, got %d", http.StatusBadRequest, rec.Code)
+	}
+
+	// sweeping the board row by row, salvo size follows surviving ships
+	next, computerShips, playerShips := 0, 3, 3
+	var response SalvoResponse
+	for response.Status != models.StatusWon && response.Status != models.StatusLost {
+		var cells []string
+		for i := 0; i < playerShips; i++ {
+			cells = append(cells, fmt.Sprintf(`{"x": %d, "y": %d}`, next%6, next/6))
+			next++
+		}
+
+		rec = doRequest(server, "POST", url, `{"cells": [`+strings.Join(cells, ",")+`]}`)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected %d on salvo, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+		}
+		response = SalvoResponse{}
+		json.Unmarshal(rec.Body.Bytes(), &response)
+
+		if len(response.Salvo.Shots) != len(cells) {
+			t.Fatalf("expected %d shots resolved, got %d", len(cells), len(response.Salvo.Shots))
+		}
+		computerShips -= len(response.Salvo.DeadShips)
+
+		if response.ComputerSalvo != nil {
+			// sinks of player salvo already count
+			if len(response.ComputerSalvo.Shots) != computerShips {
+				t.Fatalf("expected computer to fire %d shots, got %d", computerShips, len(response.ComputerSalvo.Shots))
+			}
+			playerShips -= len(response.ComputerSalvo.DeadShips)
+		}
+	}
+
+	if (response.Status == models.StatusWon) != (computerShips == 0) || (response.Status == models.StatusLost) != (playerShips == 0) {
+		t.Errorf("unexpected %s with %d computer and %d player ships left", response.Status, computerShips, playerShips)
+	}
+
+	rec = doRequest(server, "GET", "/api/v1/session?session_id="+created.ID, "")
+	var loaded SessionResponse
+	json.Unmarshal(rec.Body.Bytes(), &loaded)
+	if loaded.Status != response.Status || len(loaded.ComputerDeadShips) != 3-computerShips {
+		t.Errorf("expected replayed session to match the game, got %s with %d dead ships", loaded.Status, len(loaded.ComputerDeadShips))
+	}
+}
@@ -3,6 +3,7 @@ package v1
 import (
 	"encoding/json"
 	"errors"
+	"io"
 	"net/http"
 
 	"github.com/gorilla/mux"
@@ -11,24 +12,45 @@ import (
 
 	"github.com/billyboar/battleships/helpers"
 	"github.com/billyboar/battleships/models"
+	"github.com/billyboar/battleships/models/db"
 )
 
 // LoadSessionRoutes will register board endpoints to /api/v1 prefix
 func (s *APIServer) LoadSessionRoutes(router *mux.Router) {
+	router.HandleFunc("/sessions", s.ListSessions).Methods("GET")
+	router.HandleFunc("/rules", s.ListRuleSets).Methods("GET")
+
 	sessionRouter := router.PathPrefix("/session").Subrouter()
 	c := claw.New()
 
 	sessionRouter.HandleFunc("", s.CreateSession).Methods("POST")
+	sessionRouter.HandleFunc("/restore", s.RestoreSession).Methods("POST")
 	sessionRouter.Handle("", c.Use(s.GetSession).Add(s.LoadSessionToCtx)).Methods("GET")
 	sessionRouter.Handle("/shoot", c.Use(s.ShootShip).Add(s.LoadSessionToCtx))
+	sessionRouter.Handle("/weapon", c.Use(s.UseWeapon).Add(s.LoadSessionToCtx)).Methods("POST")
+	sessionRouter.Handle("/fleet", c.Use(s.PlaceFleet).Add(s.LoadSessionToCtx)).Methods("PUT")
+	sessionRouter.Handle("/forfeit", c.Use(s.ForfeitSession).Add(s.LoadSessionToCtx)).Methods("POST")
+	sessionRouter.Handle("/abandon", c.Use(s.AbandonSession).Add(s.LoadSessionToCtx)).Methods("POST")
 }
 
 type SessionResponse struct {
-	ID                 string              `json:"id"`
-	Player             *models.Board       `json:"player"`
-	ComputerShipWounds []models.Cell       `json:"computer_ship_wounds"`
-	ComputerDeadShips  []models.BattleShip `json:"computer_dead_ships"`
-	PlayerMissedShots  []models.Cell       `json:"player_missed_shots"`
+	ID                 string               `json:"id"`
+	PlayerID           string               `json:"player_id,omitempty"`
+	Status             models.SessionStatus `json:"status"`
+	Winner             string               `json:"winner,omitempty"`
+	Turn               string               `json:"turn"`
+	Rules              *models.RuleSet      `json:"rules"`
+	Difficulty         string               `json:"difficulty"`
+	Player             *models.Board        `json:"player"`
+	ComputerShipWounds []models.Cell        `json:"computer_ship_wounds"`
+	ComputerDeadShips  []models.BattleShip  `json:"computer_dead_ships"`
+	PlayerMissedShots  []models.Cell        `json:"player_missed_shots"`
+	ComputerTerrain    []models.Cell        `json:"computer_terrain,omitempty"`
+	// PlayerSonarScans are readings of player's sonar on computer board
+	PlayerSonarScans []models.SonarScan `json:"player_sonar_scans,omitempty"`
+	Ammo             map[string]int     `json:"ammo,omitempty"` // special weapons player has left
+	// ReplayErrors lists damaged events skipped while loading session
+	ReplayErrors []*models.ReplayError `json:"replay_errors,omitempty"`
 }
 
 func (s *APIServer) GetSession(w http.ResponseWriter, r *http.Request) {
@@ -36,50 +58,280 @@ func (s *APIServer) GetSession(w http.ResponseWriter, r *http.Request) {
 
 	response := SessionResponse{
 		ID:                 session.ID,
+		PlayerID:           session.PlayerID,
+		Status:             session.Status,
+		Winner:             session.Winner(),
+		Turn:               session.Turn,
+		Rules:              session.Rules,
+		Difficulty:         session.Difficulty,
 		Player:             session.Player,
 		ComputerDeadShips:  session.Computer.GetDeadShips(),
 		ComputerShipWounds: session.Computer.GetAllShipWounds(),
 		PlayerMissedShots:  session.Computer.MissedShots,
+		ComputerTerrain:    session.Computer.Terrain,
+		PlayerSonarScans:   session.Computer.SonarScans,
+		Ammo:               session.Ammo(false),
+		ReplayErrors:       session.ReplayErrors,
+	}
+	// wounds listed ship by ship would tell which hits belong together
+	if sinksHidden(session) {
+		response.ComputerDeadShips = []models.BattleShip{}
+		response.ComputerShipWounds = session.Computer.Hits()
 	}
 
 	helpers.RenderJSON(w, response, http.StatusOK)
 }
 
+// sinksHidden reports if player may not be told about sunk computer
+// ships yet, see RuleSet HiddenSinks
+func sinksHidden(session *models.Session) bool {
+	return session.Rules.HiddenSinks && !session.Status.IsFinished()
+}
+
+type CreateSessionRequest struct {
+	PlayerID string `json:"player_id"`
+	Rules    string `json:"rules"` // rule set name, default rules when empty
+	// NoTouching turns on no touching rule for chosen rule set
+	NoTouching bool `json:"no_touching"`
+	// Salvo turns on salvo mode for chosen rule set
+	Salvo bool `json:"salvo"`
+	// ExtraShotOnHit lets side keep shooting after a hit
+	ExtraShotOnHit bool `json:"extra_shot_on_hit"`
+	// HiddenSinks keeps sunk ships secret until the game is over
+	HiddenSinks bool `json:"hidden_sinks"`
+	// Difficulty selects computer shot strategy, default when empty
+	Difficulty string `json:"difficulty"`
+	// ManualPlacement starts session in setup, see PlaceFleet
+	ManualPlacement bool `json:"manual_placement"`
+}
+
 // CreateSession creates new session with randomly placed ships
-// for both players
+// for both players. Request body is optional
 func (s *APIServer) CreateSession(w http.ResponseWriter, r *http.Request) {
-	session, err := models.NewSession()
+	var req CreateSessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		helpers.RenderError(w, "cannot decode session request", err, http.StatusBadRequest)
+		return
+	}
+
+	rules, err := models.RuleSetByName(req.Rules)
+	if err != nil {
+		helpers.RenderError(w, "unknown rule set", err, http.StatusBadRequest)
+		return
+	}
+	if req.NoTouching {
+		rules.NoTouching = true
+	}
+	if req.Salvo {
+		rules.Salvo = true
+	}
+	if req.ExtraShotOnHit {
+		rules.ExtraShotOnHit = true
+	}
+	if req.HiddenSinks {
+		rules.HiddenSinks = true
+	}
+	if err := rules.Validate(); err != nil {
+		helpers.RenderError(w, "rules cannot be combined", err, http.StatusBadRequest)
+		return
+	}
+	if _, err := models.ShotStrategyByName(req.Difficulty); err != nil {
+		helpers.RenderError(w, "unknown difficulty", err, http.StatusBadRequest)
+		return
+	}
+
+	newSession := models.NewSessionWithRules
+	if req.ManualPlacement {
+		newSession = models.NewSetupSession
+	}
+
+	session, err := newSession(rules)
+	if err == models.ErrNoValidLayout {
+		helpers.RenderError(w, "fleet does not fit the board", err, http.StatusUnprocessableEntity)
+		return
+	}
 	if err != nil {
 		helpers.RenderError(w, "cannot generate new session", err, http.StatusInternalServerError)
 		return
 	}
+	session.PlayerID = req.PlayerID
+	if req.Difficulty != "" {
+		session.Difficulty = req.Difficulty
+	}
 
 	event := models.CreateNewSessionEvent(session)
-	if err := s.Store.AppendEvent(session.ID, event); err != nil {
-		helpers.RenderError(w, "cannot append event to store", err, http.StatusInternalServerError)
+	if err := s.Store.AppendEvent(session.ID, 0, event); err != nil {
+		renderAppendError(w, err)
 		return
 	}
 
 	response := SessionResponse{
-		ID:     session.ID,
-		Player: session.Player,
+		ID:         session.ID,
+		PlayerID:   session.PlayerID,
+		Status:     session.Status,
+		Turn:       session.Turn,
+		Rules:      session.Rules,
+		Difficulty: session.Difficulty,
+		Player:     session.Player,
 	}
 
 	// @TODO! return token
 	helpers.RenderJSON(w, response, http.StatusCreated)
 }
 
+// renderAppendError renders failed append, reporting concurrent
+// modification of the session as conflict
+func renderAppendError(w http.ResponseWriter, err error) {
+	if err == db.ErrVersionConflict {
+		helpers.RenderError(w, "session was modified by another request", err, http.StatusConflict)
+		return
+	}
+	helpers.RenderError(w, "cannot append event to store", err, http.StatusInternalServerError)
+}
+
+// RestoreSession brings archived session back into live store
+// and returns it. Sessions archived while idle are not abandoned,
+// restored game continues where it was left
+func (s *APIServer) RestoreSession(w http.ResponseWriter, r *http.Request) {
+	if s.Archive == nil {
+		helpers.RenderError(w, "session archive is disabled", nil, http.StatusNotFound)
+		return
+	}
+
+	sessionID := r.URL.Query().Get("session_id")
+	if err := validateSessionID(sessionID); err != nil {
+		helpers.RenderError(w, "session_id must be a UUID", err, http.StatusBadRequest)
+		return
+	}
+
+	err := db.RestoreSession(s.Store, s.Archive, sessionID)
+	if err == db.ErrNotArchived {
+		helpers.RenderError(w, "session is not archived", err, http.StatusNotFound)
+		return
+	}
+	if err == db.ErrVersionConflict {
+		helpers.RenderError(w, "session is already live", err, http.StatusConflict)
+		return
+	}
+	if err != nil {
+		helpers.RenderError(w, "cannot restore session", err, http.StatusInternalServerError)
+		return
+	}
+
+	// serve restored session the same way GET does
+	s.LoadSessionToCtx(http.HandlerFunc(s.GetSession)).ServeHTTP(w, r)
+}
+
+type PlaceFleetRequest struct {
+	Ships   []models.ShipPlacement `json:"ships"`
+	Confirm bool                   `json:"confirm"` // ends setup, fleet must be complete
+}
+
+// PlaceFleet stores fleet positioned by the player during setup,
+// the fleet can be changed until it is confirmed
+func (s *APIServer) PlaceFleet(w http.ResponseWriter, r *http.Request) {
+	var req PlaceFleetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		helpers.RenderError(w, "cannot decode fleet request", err, http.StatusBadRequest)
+		return
+	}
+
+	session := r.Context().Value(SessionCtx).(*models.Session)
+
+	event, err := session.PlaceFleet(req.Ships, req.Confirm)
+	if err == models.ErrNotInSetup {
+		helpers.RenderError(w, "fleet cannot be changed anymore", err, http.StatusConflict)
+		return
+	}
+	if err != nil {
+		helpers.RenderError(w, "fleet is not valid", err, http.StatusUnprocessableEntity)
+		return
+	}
+
+	if err := s.Store.AppendEvent(session.ID, session.Version, event); err != nil {
+		renderAppendError(w, err)
+		return
+	}
+
+	s.GetSession(w, r)
+}
+
+// ForfeitSession ends the game on behalf of the player, computer wins it
+func (s *APIServer) ForfeitSession(w http.ResponseWriter, r *http.Request) {
+	s.endSession(w, r, models.StatusForfeited)
+}
+
+// AbandonSession ends the game without a winner
+func (s *APIServer) AbandonSession(w http.ResponseWriter, r *http.Request) {
+	s.endSession(w, r, models.StatusAbandoned)
+}
+
+// endSession moves session to finished status and returns it
+func (s *APIServer) endSession(w http.ResponseWriter, r *http.Request, status models.SessionStatus) {
+	session := r.Context().Value(SessionCtx).(*models.Session)
+
+	event, err := session.ChangeStatus(status)
+	if err != nil {
+		helpers.RenderError(w, "cannot end session", err, http.StatusConflict)
+		return
+	}
+
+	if err := s.Store.AppendEvent(session.ID, session.Version, event); err != nil {
+		renderAppendError(w, err)
+		return
+	}
+
+	s.GetSession(w, r)
+}
+
+// renderMoveError renders rejected move with status matching the reason
+func renderMoveError(w http.ResponseWriter, err error) {
+	switch err {
+	case models.ErrCellOutOfBoard:
+		helpers.RenderError(w, "shoot cell is not valid", err, http.StatusBadRequest)
+	case models.ErrTerrainCell:
+		helpers.RenderError(w, "cell is covered by terrain", err, http.StatusUnprocessableEntity)
+	case models.ErrAlreadyShot:
+		helpers.RenderError(w, "cell was already shot", err, http.StatusUnprocessableEntity)
+	case models.ErrOutOfTurn:
+		helpers.RenderError(w, "it is not your turn", err, http.StatusConflict)
+	case models.ErrFleetNotPlaced:
+		helpers.RenderError(w, "fleet is not placed yet", err, http.StatusConflict)
+	case models.ErrGameOver:
+		helpers.RenderError(w, "game is over", err, http.StatusConflict)
+	case models.ErrSalvoSize:
+		helpers.RenderError(w, "salvo has wrong number of shots", err, http.StatusBadRequest)
+	case models.ErrUnknownWeapon:
+		helpers.RenderError(w, "weapon is not allowed by the rules", err, http.StatusBadRequest)
+	case models.ErrNoAmmo:
+		helpers.RenderError(w, "weapon has no ammo left", err, http.StatusConflict)
+	default:
+		helpers.RenderError(w, "move is not allowed", err, http.StatusBadRequest)
+	}
+}
+
 type ShootShipRequest struct {
 	models.Cell
+	Cells []models.Cell `json:"cells"` // shots of the salvo in salvo mode
+}
+
+type ComputerMove struct {
+	models.Cell
+	DeadShip *models.BattleShip `json:"dead_ship"`
+	// Weapon is set when computer used special weapon at the cell
+	Weapon *models.WeaponResult `json:"weapon,omitempty"`
 }
 
 type ShootShipResponse struct {
-	IsDead       bool               `json:"is_dead"`
-	DeadShip     *models.BattleShip `json:"dead_ship"`
-	ComputerMove struct {
-		models.Cell
-		DeadShip *models.BattleShip `json:"dead_ship"`
-	} `json:"computer_move"`
+	IsDead   bool               `json:"is_dead"`
+	DeadShip *models.BattleShip `json:"dead_ship"`
+	// ComputerMove is the first of ComputerMoves, empty when computer
+	// did not move because the game ended or player kept the turn
+	ComputerMove  *ComputerMove        `json:"computer_move,omitempty"`
+	ComputerMoves []ComputerMove       `json:"computer_moves,omitempty"`
+	Status        models.SessionStatus `json:"status"`
+	Winner        string               `json:"winner,omitempty"`
+	Turn          string               `json:"turn"` // side making the next move
 }
 
 // ShootShip handles shooting ships for player side
@@ -93,58 +345,118 @@ func (s *APIServer) ShootShip(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !req.IsValid() {
-		helpers.RenderError(w, "shoot cell is not valid", errors.New("validation failed"), http.StatusBadRequest)
+	session := r.Context().Value(SessionCtx).(*models.Session)
+	if session.Rules.Salvo {
+		s.shootSalvo(w, session, req.Cells)
 		return
 	}
 
-	session := r.Context().Value(SessionCtx).(*models.Session)
-
-	event := models.CreateShootEvent(session.ID, &req.Cell, false)
-	if err := s.Store.AppendEvent(session.ID, event); err != nil {
-		helpers.RenderError(w, "cannot append event to store", err, http.StatusInternalServerError)
+	shotStatus, deadShipID, err := session.Shoot(req.Cell, false)
+	if err != nil {
+		renderMoveError(w, err)
 		return
 	}
 
-	shotStatus, deadShipID := session.Computer.RegisterShot(req.Cell)
+	// all events of the turn are committed together at the end
+	events := []*models.Event{
+		models.CreateShootEvent(session.ID, &req.Cell, false, session.Turn),
+	}
+
 	response := ShootShipResponse{
 		IsDead: shotStatus,
 	}
 
 	if deadShip := session.Computer.MarkShipIfDead(deadShipID); deadShip != nil {
-		event = models.CreateDestroyShipEvent(session.ID, deadShipID, true)
-		if err := s.Store.AppendEvent(session.ID, event); err != nil {
-			helpers.RenderError(w, "cannot append event to store", err, http.StatusInternalServerError)
-			return
-		}
+		events = append(events, models.CreateDestroyShipEvent(session.ID, deadShipID, true))
 		response.DeadShip = deadShip
 	}
 
-	// calculate computer response
-	computerShot := session.Player.CalculateShot()
-	if computerShot == nil {
-		helpers.RenderError(w, "cannot find move for computer", nil, http.StatusInternalServerError)
+	computerMoves, computerEvents, err := playComputerTurn(session)
+	if err != nil {
+		helpers.RenderError(w, "computer cannot move", err, http.StatusInternalServerError)
 		return
 	}
-	response.ComputerMove.Cell = *computerShot
+	events = append(events, computerEvents...)
+	response.ComputerMoves = computerMoves
+	if len(response.ComputerMoves) > 0 {
+		response.ComputerMove = &response.ComputerMoves[0]
+	}
+
+	response.Status = session.Status
+	response.Winner = session.Winner()
+	response.Turn = session.Turn
+	if sinksHidden(session) {
+		response.DeadShip = nil
+	}
 
-	// creating shoot event for computer
-	event = models.CreateShootEvent(session.ID, &response.ComputerMove.Cell, true)
-	if err := s.Store.AppendEvent(session.ID, event); err != nil {
-		helpers.RenderError(w, "cannot append event to store", err, http.StatusInternalServerError)
+	if err := s.Store.AppendEvents(session.ID, session.Version, events...); err != nil {
+		renderAppendError(w, err)
 		return
 	}
 
-	response.ComputerMove.Cell.IsDead, deadShipID = session.Player.RegisterShot(response.ComputerMove.Cell)
-	if deadShip := session.Player.MarkShipIfDead(deadShipID); deadShip != nil {
-		event = models.CreateDestroyShipEvent(session.ID, deadShipID, false)
-		if err := s.Store.AppendEvent(session.ID, event); err != nil {
-			helpers.RenderError(w, "cannot append event to store", err, http.StatusInternalServerError)
-			return
+	helpers.RenderJSON(w, response, http.StatusOK)
+}
+
+// playComputerTurn lets computer move while it has the turn and both
+// fleets are afloat, with extra shot on hit it keeps shooting until it
+// misses. Returned events record the moves and the end of the game
+func playComputerTurn(session *models.Session) (moves []ComputerMove, events []*models.Event, err error) {
+	finishEvent, err := session.FinishIfFleetDestroyed()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for finishEvent == nil && session.Turn == models.SideComputer {
+		move, moveEvents, err := playComputerMove(session)
+		if err != nil {
+			return nil, nil, err
 		}
+		moves = append(moves, move)
+		events = append(events, moveEvents...)
 
-		response.ComputerMove.DeadShip = deadShip
+		if finishEvent, err = session.FinishIfFleetDestroyed(); err != nil {
+			return nil, nil, err
+		}
 	}
 
-	helpers.RenderJSON(w, response, http.StatusOK)
+	if finishEvent != nil {
+		events = append(events, finishEvent)
+	}
+	return moves, events, nil
+}
+
+// playComputerMove makes single computer move, a shot or use of
+// special weapon
+func playComputerMove(session *models.Session) (ComputerMove, []*models.Event, error) {
+	if weapon := session.PlanComputerWeapon(); weapon != nil {
+		result, event, err := session.UseWeapon(*weapon, true)
+		if err != nil {
+			return ComputerMove{}, nil, err
+		}
+
+		events := []*models.Event{event}
+		for _, deadShip := range result.DeadShips {
+			events = append(events, models.CreateDestroyShipEvent(session.ID, deadShip.ID, false))
+		}
+		return ComputerMove{Cell: weapon.Target, Weapon: result}, events, nil
+	}
+
+	computerShot := session.ComputerShot()
+	if computerShot == nil {
+		return ComputerMove{}, nil, errors.New("no cell is left to shoot")
+	}
+	move := ComputerMove{Cell: *computerShot}
+
+	isHit, deadShipID, err := session.Shoot(*computerShot, true)
+	if err != nil {
+		return ComputerMove{}, nil, err
+	}
+	move.Cell.IsDead = isHit
+	events := []*models.Event{models.CreateShootEvent(session.ID, computerShot, true, session.Turn)}
+
+	if deadShip := session.Player.MarkShipIfDead(deadShipID); deadShip != nil {
+		events = append(events, models.CreateDestroyShipEvent(session.ID, deadShipID, false))
+		move.DeadShip = deadShip
+	}
+	return move, events, nil
 }
@@ -0,0 +1,518 @@
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/billyboar/battleships/config"
+	"github.com/billyboar/battleships/models"
+	"github.com/billyboar/battleships/models/db"
+)
+
+func newTestServer() *APIServer {
+	server := NewAPIServer(db.NewMemoryStore(), &config.Config{SnapshotFrequency: 2, StrictReplay: true})
+	server.RegisterRoutes()
+	return server
+}
+
+func doRequest(server *APIServer, method, url, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, url, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	server.Router.ServeHTTP(rec, req)
+	return rec
+}
+
+func createTestSession(t *testing.T, server *APIServer) SessionResponse {
+	rec := doRequest(server, "POST", "/api/v1/session", "")
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected %d on create, got %d: %s", http.StatusCreated, rec.Code, rec.Body)
+	}
+
+	var session SessionResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &session); err != nil {
+		t.Fatal("cannot decode session:", err)
+	}
+	return session
+}
+
+func TestSessionFlow(t *testing.T) {
+	server := newTestServer()
+	session := createTestSession(t, server)
+
+	rec := doRequest(server, "POST", "/api/v1/session/shoot?session_id="+session.ID, `{"x": 0, "y": 0}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected %d on shoot, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+
+	rec = doRequest(server, "GET", "/api/v1/session?session_id="+session.ID, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected %d on get, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+
+	var loaded SessionResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &loaded); err != nil {
+		t.Fatal("cannot decode session:", err)
+	}
+	if shots := len(loaded.PlayerMissedShots) + len(loaded.ComputerShipWounds); shots != 1 {
+		t.Errorf("expected 1 player shot to be registered, got %d", shots)
+	}
+	if shots := len(loaded.Player.MissedShots) + len(loaded.Player.GetAllShipWounds()); shots != 1 {
+		t.Errorf("expected 1 computer shot to be registered, got %d", shots)
+	}
+}
+
+func TestLoadSessionErrors(t *testing.T) {
+	server := newTestServer()
+
+	rec := doRequest(server, "GET", "/api/v1/session?session_id=6ba7b810-9dad-11d1-80b4-00c04fd430c8", "")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected %d for missing session, got %d", http.StatusNotFound, rec.Code)
+	}
+
+	rec = doRequest(server, "GET", "/api/v1/session?session_id=../snapshots/x", "")
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected %d for session ID which is not UUID, got %d", http.StatusBadRequest, rec.Code)
+	}
+
+	session := createTestSession(t, server)
+	damaged := &models.Event{EventType: models.ShootEventType, Data: "{not json"}
+	if err := server.Store.AppendEvent(session.ID, db.AnyVersion, damaged); err != nil {
+		t.Fatal("failed to append event:", err)
+	}
+
+	rec = doRequest(server, "GET", "/api/v1/session?session_id="+session.ID, "")
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected %d for damaged session, got %d", http.StatusUnprocessableEntity, rec.Code)
+	}
+}
+
+func TestLenientReplayRequiresNewSession(t *testing.T) {
+	server := NewAPIServer(db.NewMemoryStore(), &config.Config{StrictReplay: false})
+	server.RegisterRoutes()
+
+	sessionID := "6ba7b810-9dad-11d1-80b4-00c04fd430c8"
+	damaged := &models.Event{EventType: models.NewSessionEventType, Data: "{broken"}
+	if err := server.Store.AppendEvent(sessionID, 0, damaged); err != nil {
+		t.Fatal("failed to append event:", err)
+	}
+
+	rec := doRequest(server, "GET", "/api/v1/session?session_id="+sessionID, "")
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected %d for session without new_session event, got %d", http.StatusUnprocessableEntity, rec.Code)
+	}
+}
+
+func TestRestoreSessionRejectsInvalidID(t *testing.T) {
+	server := newTestServer()
+	server.Archive = db.NewArchive("archive")
+
+	rec := doRequest(server, "POST", "/api/v1/session/restore?session_id=../events", "")
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected %d for session ID which is not UUID, got %d", http.StatusBadRequest, rec.Code)
+	}
+
+	rec = doRequest(server, "POST", "/api/v1/session/restore?session_id=6ba7b810-9dad-11d1-80b4-00c04fd430c8", "")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected %d for session which is not archived, got %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestListSessions(t *testing.T) {
+	server := newTestServer()
+	createTestSession(t, server)
+	rec := doRequest(server, "POST", "/api/v1/session", `{"player_id": "alice"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected %d on create, got %d: %s", http.StatusCreated, rec.Code, rec.Body)
+	}
+
+	rec = doRequest(server, "GET", "/api/v1/sessions?player_id=alice&sort=last_activity", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected %d on list, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+
+	var page db.SessionPage
+	if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+		t.Fatal("cannot decode sessions:", err)
+	}
+	if len(page.Sessions) != 1 || page.Sessions[0].PlayerID != "alice" {
+		t.Errorf("expected single session of alice, got %+v", page.Sessions)
+	}
+
+	rec = doRequest(server, "GET", "/api/v1/sessions?limit=1000", "")
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected %d for too large limit, got %d", http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestShootUntilGameOver(t *testing.T) {
+	server := newTestServer()
+	created := createTestSession(t, server)
+
+	session, err := db.LoadSession(server.Store, created.ID, server.Config)
+	if err != nil {
+		t.Fatal("failed to load session:", err)
+	}
+
+	var response ShootShipResponse
+	for _, ship := range session.Computer.Battleships {
+		for _, cell := range ship.Cells {
+			body := fmt.Sprintf(`{"x": %d, "y": %d}`, cell.X, cell.Y)
+			rec := doRequest(server, "POST", "/api/v1/session/shoot?session_id="+created.ID, body)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected %d on shoot, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+			}
+			response = ShootShipResponse{}
+			json.Unmarshal(rec.Body.Bytes(), &response)
+		}
+	}
+
+	if response.Status != models.StatusWon || response.Winner != models.SidePlayer {
+		t.Errorf("expected player to win, got %s won by %q", response.Status, response.Winner)
+	}
+	if response.ComputerMove != nil {
+		t.Errorf("expected computer not to move after losing, got %+v", response.ComputerMove)
+	}
+
+	rec := doRequest(server, "POST", "/api/v1/session/shoot?session_id="+created.ID, `{"x": 0, "y": 0}`)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("expected %d for shot after game over, got %d", http.StatusConflict, rec.Code)
+	}
+}
+
+func TestEndSession(t *testing.T) {
+	server := newTestServer()
+	for _, test := range []struct {
+		action string
+		status models.SessionStatus
+		winner string
+	}{
+		{"abandon", models.StatusAbandoned, ""},
+		{"forfeit", models.StatusForfeited, models.SideComputer},
+	} {
+		created := createTestSession(t, server)
+		url := "/api/v1/session/" + test.action + "?session_id=" + created.ID
+
+		rec := doRequest(server, "POST", url, "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected %d on %s, got %d: %s", http.StatusOK, test.action, rec.Code, rec.Body)
+		}
+		var response SessionResponse
+		json.Unmarshal(rec.Body.Bytes(), &response)
+		if response.Status != test.status || response.Winner != test.winner {
+			t.Errorf("expected %s session won by %q, got %s won by %q", test.status, test.winner, response.Status, response.Winner)
+		}
+
+		if rec := doRequest(server, "POST", url, ""); rec.Code != http.StatusConflict {
+			t.Errorf("expected %d on %s of finished session, got %d", http.StatusConflict, test.action, rec.Code)
+		}
+	}
+}
+
+func TestHiddenSinks(t *testing.T) {
+	server := newTestServer()
+
+	rec := doRequest(server, "POST", "/api/v1/session", `{"hidden_sinks": true}`)
+	var created SessionResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatal("cannot decode session:", err)
+	}
+	session, err := db.LoadSession(server.Store, created.ID, server.Config)
+	if err != nil {
+		t.Fatal("failed to load session:", err)
+	}
+
+	var response ShootShipResponse
+	for i, ship := range session.Computer.Battleships {
+		for _, cell := range ship.Cells {
+			body := fmt.Sprintf(`{"x": %d, "y": %d}`, cell.X, cell.Y)
+			rec := doRequest(server, "POST", "/api/v1/session/shoot?session_id="+created.ID, body)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected %d on shoot, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+			}
+			response = ShootShipResponse{}
+			json.Unmarshal(rec.Body.Bytes(), &response)
+
+			if response.Status != models.StatusWon && response.DeadShip != nil {
+				t.Fatalf("expected sunk ship to be hidden, got %s", response.DeadShip.Name)
+			}
+		}
+
+		if i == 0 {
+			rec := doRequest(server, "GET", "/api/v1/session?session_id="+created.ID, "")
+			var loaded SessionResponse
+			json.Unmarshal(rec.Body.Bytes(), &loaded)
+			if len(loaded.ComputerDeadShips) != 0 || len(loaded.ComputerShipWounds) != len(ship.Cells) {
+				t.Errorf("expected only hits to be shown, got %d dead ships and %d wounds", len(loaded.ComputerDeadShips), len(loaded.ComputerShipWounds))
+			}
+		}
+	}
+
+	if response.Status != models.StatusWon || response.DeadShip == nil {
+		t.Errorf("expected last sunk ship to be revealed with the win, got %s", response.Status)
+	}
+}
+
+func TestRejectRepeatedShot(t *testing.T) {
+	server := newTestServer()
+	session := createTestSession(t, server)
+
+	url := "/api/v1/session/shoot?session_id=" + session.ID
+	if rec := doRequest(server, "POST", url, `{"x": 5, "y": 5}`); rec.Code != http.StatusOK {
+		t.Fatalf("expected %d on shoot, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+	if rec := doRequest(server, "POST", url, `{"x": 5, "y": 5}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected %d on repeated shot, got %d", http.StatusUnprocessableEntity, rec.Code)
+	}
+	if rec := doRequest(server, "POST", url, `{"x": -1, "y": 5}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected %d on shot outside board, got %d", http.StatusBadRequest, rec.Code)
+	}
+
+	events, _ := server.Store.GetEvents(session.ID)
+	if len(events) != 3 {
+		t.Errorf("expected rejected shots to write no events, got %d events", len(events))
+	}
+}
+
+// racingStore appends event right after session events are read, as if
+// another request modified session while it was being handled
+type racingStore struct {
+	db.EventStore
+	event *models.Event
+}
+
+func (store *racingStore) race(sessionID string) {
+	if store.event != nil {
+		store.EventStore.AppendEvent(sessionID, db.AnyVersion, store.event)
+		store.event = nil
+	}
+}
+
+func (store *racingStore) GetEvents(sessionID string) ([]*models.Event, error) {
+	events, err := store.EventStore.GetEvents(sessionID)
+	store.race(sessionID)
+	return events, err
+}
+
+func (store *racingStore) GetEventsSince(snapshot *models.Snapshot) ([]*models.Event, error) {
+	events, err := store.EventStore.GetEventsSince(snapshot)
+	store.race(snapshot.SessionID)
+	return events, err
+}
+
+func TestShootConflictsWithConcurrentMove(t *testing.T) {
+	store := &racingStore{EventStore: db.NewMemoryStore()}
+	server := NewAPIServer(store, &config.Config{SnapshotFrequency: 2, StrictReplay: true})
+	server.RegisterRoutes()
+	session := createTestSession(t, server)
+
+	cell := models.Cell{X: 1, Y: 1}
+	store.event = models.CreateShootEvent(session.ID, &cell, false, models.SideComputer)
+
+	rec := doRequest(server, "POST", "/api/v1/session/shoot?session_id="+session.ID, `{"x": 5, "y": 5}`)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("expected %d for stale session, got %d: %s", http.StatusConflict, rec.Code, rec.Body)
+	}
+
+	// only the concurrent move is stored after new_session
+	if events, _ := store.GetEvents(session.ID); len(events) != 2 {
+		t.Errorf("expected 2 events, got %d", len(events))
+	}
+}
+
+func TestCreateSessionWithRules(t *testing.T) {
+	server := newTestServer()
+
+	rec := doRequest(server, "POST", "/api/v1/session", `{"rules": "seabattle"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected %d on create, got %d: %s", http.StatusCreated, rec.Code, rec.Body)
+	}
+
+	var created SessionResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatal("cannot decode session:", err)
+	}
+	if len(created.Player.Battleships) != 10 {
+		t.Errorf("expected 10 ships in seabattle fleet, got %d", len(created.Player.Battleships))
+	}
+
+	rec = doRequest(server, "GET", "/api/v1/session?session_id="+created.ID, "")
+	var loaded SessionResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &loaded); err != nil {
+		t.Fatal("cannot decode session:", err)
+	}
+	if loaded.Rules == nil || loaded.Rules.Name != "seabattle" {
+		t.Errorf("expected seabattle rules to be replayed, got %+v", loaded.Rules)
+	}
+
+	rec = doRequest(server, "POST", "/api/v1/session", `{"rules": "chess"}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected %d for unknown rules, got %d", http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestCreateSessionWithDifficulty(t *testing.T) {
+	server := newTestServer()
+
+	rec := doRequest(server, "POST", "/api/v1/session", `{"difficulty": "impossible"}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected %d for unknown difficulty, got %d", http.StatusBadRequest, rec.Code)
+	}
+
+	rec = doRequest(server, "POST", "/api/v1/session", `{"difficulty": "monte_carlo"}`)
+	var created SessionResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatal("cannot decode session:", err)
+	}
+
+	rec = doRequest(server, "POST", "/api/v1/session/shoot?session_id="+created.ID, `{"x": 0, "y": 0}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected %d on shoot, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+
+	rec = doRequest(server, "GET", "/api/v1/session?session_id="+created.ID, "")
+	var loaded SessionResponse
+	json.Unmarshal(rec.Body.Bytes(), &loaded)
+	if loaded.Difficulty != models.DifficultyMonteCarlo {
+		t.Errorf("expected replayed session to keep %q difficulty, got %q", models.DifficultyMonteCarlo, loaded.Difficulty)
+	}
+}
+
+func TestManualPlacement(t *testing.T) {
+	server := newTestServer()
+
+	rec := doRequest(server, "POST", "/api/v1/session", `{"rules": "quick", "manual_placement": true}`)
+	var session SessionResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &session); err != nil {
+		t.Fatal("cannot decode session:", err)
+	}
+	if session.Status != models.StatusSetup || len(session.Player.Battleships) != 0 {
+		t.Fatalf("expected empty fleet in setup, got %s with %d ships", session.Status, len(session.Player.Battleships))
+	}
+
+	shootURL := "/api/v1/session/shoot?session_id=" + session.ID
+	fleetURL := "/api/v1/session/fleet?session_id=" + session.ID
+
+	rec = doRequest(server, "POST", shootURL, `{"x": 0, "y": 0}`)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("expected %d for shot during setup, got %d", http.StatusConflict, rec.Code)
+	}
+
+	cruiser := `{"name": "cruiser", "head": {"x": 0, "y": 0}, "is_vertical": true}`
+	steps := []struct {
+		body   string
+		code   int
+		status models.SessionStatus
+	}{
+		// overlapping ships
+		{`{"ships": [` + cruiser + `, {"name": "destroyer", "head": {"x": 0, "y": 1}}]}`, http.StatusUnprocessableEntity, ""},
+		// off the board
+		{`{"ships": [{"name": "destroyer", "head": {"x": 5, "y": 5}}]}`, http.StatusUnprocessableEntity, ""},
+		// incomplete fleet cannot be confirmed
+		{`{"ships": [` + cruiser + `], "confirm": true}`, http.StatusUnprocessableEntity, ""},
+		{`{"ships": [` + cruiser + `]}`, http.StatusOK, models.StatusSetup},
+		{`{"ships": [` + cruiser + `, {"name": "destroyer", "head": {"x": 2, "y": 0}}, {"name": "destroyer", "head": {"x": 2, "y": 4}}], "confirm": true}`, http.StatusOK, models.StatusCreated},
+		{`{"ships": [` + cruiser + `]}`, http.StatusConflict, ""},
+	}
+	for i, step := range steps {
+		rec = doRequest(server, "PUT", fleetURL, step.body)
+		if rec.Code != step.code {
+			t.Fatalf("step %d: expected %d, got %d: %s", i, step.code, rec.Code, rec.Body)
+		}
+		if step.status == "" {
+			continue
+		}
+
+		var placed SessionResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &placed); err != nil {
+			t.Fatal("cannot decode session:", err)
+		}
+		if placed.Status != step.status {
+			t.Errorf("step %d: expected %s status, got %s", i, step.status, placed.Status)
+		}
+	}
+
+	rec = doRequest(server, "POST", shootURL, `{"x": 0, "y": 0}`)
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected %d for shot after setup, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+}
+
+func TestExtraShotOnHit(t *testing.T) {
+	server := newTestServer()
+
+	rec := doRequest(server, "POST", "/api/v1/session", `{"extra_shot_on_hit": true}`)
+	var created SessionResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatal("cannot decode session:", err)
+	}
+	session, err := db.LoadSession(server.Store, created.ID, server.Config)
+	if err != nil {
+		t.Fatal("failed to load session:", err)
+	}
+	url := "/api/v1/session/shoot?session_id=" + created.ID
+
+	hit := session.Computer.Battleships[0].Cells[0]
+	rec = doRequest(server, "POST", url, fmt.Sprintf(`{"x": %d, "y": %d}`, hit.X, hit.Y))
+	var response ShootShipResponse
+	json.Unmarshal(rec.Body.Bytes(), &response)
+	if !response.IsDead || response.Turn != models.SidePlayer || len(response.ComputerMoves) != 0 {
+		t.Fatalf("expected player to keep the turn after hit, got %+v", response)
+	}
+
+	var miss models.Cell
+	for x := 0; x < models.BoardRow; x++ {
+		if shot, _ := session.Computer.RegisterShot(models.Cell{X: x, Y: 9}); !shot {
+			miss = models.Cell{X: x, Y: 9}
+			break
+		}
+	}
+	rec = doRequest(server, "POST", url, fmt.Sprintf(`{"x": %d, "y": %d}`, miss.X, miss.Y))
+	response = ShootShipResponse{}
+	json.Unmarshal(rec.Body.Bytes(), &response)
+	if len(response.ComputerMoves) == 0 || response.ComputerMove == nil {
+		t.Fatalf("expected computer to move after player missed, got %+v", response)
+	}
+	for i, move := range response.ComputerMoves {
+		last := i == len(response.ComputerMoves)-1
+		if move.IsDead == last && response.Status == models.StatusInProgress {
+			t.Errorf("expected computer to shoot until it missed, got %+v", response.ComputerMoves)
+		}
+	}
+
+	rec = doRequest(server, "GET", "/api/v1/session?session_id="+created.ID, "")
+	var loaded SessionResponse
+	json.Unmarshal(rec.Body.Bytes(), &loaded)
+	if loaded.Turn != response.Turn {
+		t.Errorf("expected replayed turn %s, got %s", response.Turn, loaded.Turn)
+	}
+}
+
+func TestShootTerrain(t *testing.T) {
+	server := newTestServer()
+
+	rec := doRequest(server, "POST", "/api/v1/session", `{"rules": "islands"}`)
+	var created SessionResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatal("cannot decode session:", err)
+	}
+	if len(created.Player.Terrain) == 0 {
+		t.Fatal("expected player board to have terrain")
+	}
+
+	rec = doRequest(server, "GET", "/api/v1/session?session_id="+created.ID, "")
+	var loaded SessionResponse
+	json.Unmarshal(rec.Body.Bytes(), &loaded)
+	if len(loaded.ComputerTerrain) == 0 {
+		t.Fatal("expected computer terrain to be shown")
+	}
+
+	rock := loaded.ComputerTerrain[0]
+	rec = doRequest(server, "POST", "/api/v1/session/shoot?session_id="+created.ID, fmt.Sprintf(`{"x": %d, "y": %d}`, rock.X, rock.Y))
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected %d for shot at terrain, got %d", http.StatusUnprocessableEntity, rec.Code)
+	}
+}
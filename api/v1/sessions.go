@@ -0,0 +1,78 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/billyboar/battleships/helpers"
+	"github.com/billyboar/battleships/models"
+	"github.com/billyboar/battleships/models/db"
+)
+
+// Session list page sizes
+const (
+	defaultSessionsLimit = 20
+	maxSessionsLimit     = 100
+)
+
+// ListSessions returns page of session summaries. Supported query
+// params are player_id, status, sort (created_at or last_activity),
+// order (asc or desc), limit and cursor
+func (s *APIServer) ListSessions(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	query := db.SessionQuery{
+		PlayerID:   params.Get("player_id"),
+		Status:     models.SessionStatus(params.Get("status")),
+		SortBy:     db.SortByCreatedAt,
+		Descending: true,
+		Limit:      defaultSessionsLimit,
+		Cursor:     params.Get("cursor"),
+	}
+
+	switch sortBy := params.Get("sort"); sortBy {
+	case "":
+	case db.SortByCreatedAt, db.SortByLastActivity:
+		query.SortBy = sortBy
+	default:
+		helpers.RenderError(w, "sort must be created_at or last_activity", errors.New("validation failed"), http.StatusBadRequest)
+		return
+	}
+
+	switch order := params.Get("order"); order {
+	case "", "desc":
+	case "asc":
+		query.Descending = false
+	default:
+		helpers.RenderError(w, "order must be asc or desc", errors.New("validation failed"), http.StatusBadRequest)
+		return
+	}
+
+	if limit := params.Get("limit"); limit != "" {
+		var err error
+		query.Limit, err = strconv.Atoi(limit)
+		if err != nil || query.Limit < 1 || query.Limit > maxSessionsLimit {
+			helpers.RenderError(w, "limit must be between 1 and 100", errors.New("validation failed"), http.StatusBadRequest)
+			return
+		}
+	}
+
+	summaries, err := s.Store.ListSessions()
+	if err != nil {
+		helpers.RenderError(w, "cannot list sessions", err, http.StatusInternalServerError)
+		return
+	}
+
+	page, err := db.QuerySessions(summaries, query)
+	if err == db.ErrInvalidCursor {
+		helpers.RenderError(w, "cursor is not valid", err, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		helpers.RenderError(w, "cannot query sessions", err, http.StatusInternalServerError)
+		return
+	}
+
+	helpers.RenderJSON(w, page, http.StatusOK)
+}
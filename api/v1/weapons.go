@@ -0,0 +1,68 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/billyboar/battleships/helpers"
+	"github.com/billyboar/battleships/models"
+)
+
+type WeaponResponse struct {
+	Result        *models.WeaponResult `json:"result"`
+	ComputerMoves []ComputerMove       `json:"computer_moves,omitempty"`
+	Status        models.SessionStatus `json:"status"`
+	Winner        string               `json:"winner,omitempty"`
+	Turn          string               `json:"turn"`
+}
+
+// UseWeapon fires special weapon of the player instead of a shot,
+// computer answers the same way it answers shots
+func (s *APIServer) UseWeapon(w http.ResponseWriter, r *http.Request) {
+	var req models.WeaponMove
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		helpers.RenderError(w, "cannot decode weapon request", err, http.StatusBadRequest)
+		return
+	}
+
+	session := r.Context().Value(SessionCtx).(*models.Session)
+
+	result, event, err := session.UseWeapon(req, false)
+	if err != nil {
+		renderMoveError(w, err)
+		return
+	}
+
+	// all events of the turn are committed together at the end
+	events := []*models.Event{event}
+	for _, deadShip := range result.DeadShips {
+		events = append(events, models.CreateDestroyShipEvent(session.ID, deadShip.ID, true))
+	}
+
+	computerMoves, computerEvents, err := playComputerTurn(session)
+	if err != nil {
+		helpers.RenderError(w, "computer cannot move", err, http.StatusInternalServerError)
+		return
+	}
+	events = append(events, computerEvents...)
+
+	response := WeaponResponse{
+		Result:        result,
+		ComputerMoves: computerMoves,
+		Status:        session.Status,
+		Winner:        session.Winner(),
+		Turn:          session.Turn,
+	}
+	if sinksHidden(session) {
+		redacted := *result
+		redacted.DeadShips = nil
+		response.Result = &redacted
+	}
+
+	if err := s.Store.AppendEvents(session.ID, session.Version, events...); err != nil {
+		renderAppendError(w, err)
+		return
+	}
+
+	helpers.RenderJSON(w, response, http.StatusOK)
+}
@@ -0,0 +1,58 @@
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/billyboar/battleships/models"
+)
+
+func TestUseWeapon(t *testing.T) {
+	server := newTestServer()
+
+	session := createTestSession(t, server)
+	rec := doRequest(server, "POST", "/api/v1/session/weapon?session_id="+session.ID, `{"weapon": "sonar", "target": {"x": 4, "y": 4}}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected %d for weapon missing from the rules, got %d", http.StatusBadRequest, rec.Code)
+	}
+
+	rec = doRequest(server, "POST", "/api/v1/session", `{"rules": "arsenal"}`)
+	var created SessionResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatal("cannot decode session:", err)
+	}
+	url := "?session_id=" + created.ID
+
+	rec = doRequest(server, "POST", "/api/v1/session/weapon"+url, `{"weapon": "sonar", "target": {"x": 4, "y": 4}}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected %d on sonar, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+	var response WeaponResponse
+	json.Unmarshal(rec.Body.Bytes(), &response)
+	if response.Result == nil || response.Result.Weapon != models.WeaponSonar || len(response.ComputerMoves) == 0 {
+		t.Errorf("expected sonar result and computer answer, got %+v", response)
+	}
+
+	// computer may answer with weapons as well, replay has to follow
+	for i := 0; i < 100 && response.Status != models.StatusWon && response.Status != models.StatusLost; i++ {
+		rec = doRequest(server, "POST", "/api/v1/session/shoot"+url, fmt.Sprintf(`{"x": %d, "y": %d}`, i%10, i/10))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected %d on shot, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+		}
+		var shot ShootShipResponse
+		json.Unmarshal(rec.Body.Bytes(), &shot)
+		response.Status = shot.Status
+	}
+
+	rec = doRequest(server, "GET", "/api/v1/session"+url, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected %d on load, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+	var loaded SessionResponse
+	json.Unmarshal(rec.Body.Bytes(), &loaded)
+	if loaded.Ammo[models.WeaponSonar] != 1 || len(loaded.PlayerSonarScans) != 1 {
+		t.Errorf("expected one sonar used, got ammo %v and %d scans", loaded.Ammo, len(loaded.PlayerSonarScans))
+	}
+}
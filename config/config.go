@@ -1,9 +1,27 @@
 package config
 
+import "time"
+
 // Config contains server configs
 type Config struct {
 	DBConfig
 	ServerPort int
+	// SnapshotFrequency is the number of replayed events after which
+	// session snapshot is saved, 0 disables snapshots
+	SnapshotFrequency int
+	// StrictReplay refuses to load sessions with events which cannot be
+	// applied, otherwise such events are skipped
+	StrictReplay bool
+
+	// ArchiveDir is where expired sessions are archived to,
+	// empty disables archival and cleanup
+	ArchiveDir string
+	// IdleSessionTTL and FinishedSessionTTL are the times since last
+	// activity after which sessions are archived, 0 keeps them forever
+	IdleSessionTTL     time.Duration
+	FinishedSessionTTL time.Duration
+	// CleanupInterval is how often expired sessions are looked for
+	CleanupInterval time.Duration
 }
 
 // DBConfig contains DB configs
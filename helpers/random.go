@@ -5,7 +5,15 @@ import (
 	"time"
 )
 
-func GenerateRandomInt(limit int) int {
+func init() {
 	rand.Seed(time.Now().UnixNano())
+}
+
+func GenerateRandomInt(limit int) int {
 	return rand.Intn(limit)
 }
+
+// Shuffle randomly reorders n elements using swap
+func Shuffle(n int, swap func(i, j int)) {
+	rand.Shuffle(n, swap)
+}
@@ -5,26 +5,53 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"time"
 
 	v1 "github.com/billyboar/battleships/api/v1"
+	"github.com/billyboar/battleships/config"
+	"github.com/billyboar/battleships/models/db"
 )
 
-var portFlag string
-
 func main() {
-	// var router = mux.NewRouter()
-	// router.HandleFunc("/health", healthCheck).Methods("GET")
-
-	var port int
-
-	flag.IntVar(&port, "port", 3000, "Port number to run server on")
-
-	server, err := v1.NewAPIServer()
+	var conf config.Config
+	var storeType, dataDir string
+
+	flag.IntVar(&conf.ServerPort, "port", 3000, "Port number to run server on")
+	flag.BoolVar(&conf.StrictReplay, "strict-replay", true, "Refuse to serve sessions with damaged event history")
+	flag.StringVar(&conf.ArchiveDir, "archive-dir", "", "Directory for archived sessions, cleanup is disabled unless set")
+	flag.DurationVar(&conf.IdleSessionTTL, "idle-ttl", 24*time.Hour, "Archive unfinished sessions idle for this long, 0 keeps them")
+	flag.DurationVar(&conf.FinishedSessionTTL, "finished-ttl", time.Hour, "Archive finished sessions idle for this long, 0 keeps them")
+	flag.DurationVar(&conf.CleanupInterval, "cleanup-interval", 10*time.Minute, "How often expired sessions are archived")
+	flag.IntVar(&conf.SnapshotFrequency, "snapshot-every", 20, "Save session snapshot after replaying this many events, 0 disables snapshots")
+	flag.StringVar(&storeType, "store", "redis", "Event store backend: redis, file or memory")
+	flag.StringVar(&dataDir, "data-dir", "data", "Directory for file event store")
+	flag.Parse()
+
+	store, err := newEventStore(storeType, dataDir)
 	if err != nil {
 		panic(err)
 	}
+
+	server := v1.NewAPIServer(store, &conf)
 	server.RegisterRoutes()
 
-	fmt.Println(fmt.Sprintf("Running server on :%d", port))
-	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", port), server.Router))
+	if server.Archive != nil && conf.CleanupInterval > 0 {
+		db.NewCleaner(store, server.Archive, &conf).Start(conf.CleanupInterval)
+	}
+
+	fmt.Println(fmt.Sprintf("Running server on :%d", conf.ServerPort))
+	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", conf.ServerPort), server.Router))
+}
+
+// newEventStore creates event store backend by its name
+func newEventStore(storeType, dataDir string) (db.EventStore, error) {
+	switch storeType {
+	case "redis":
+		return db.NewStore()
+	case "file":
+		return db.NewFileStore(dataDir)
+	case "memory":
+		return db.NewMemoryStore(), nil
+	}
+	return nil, fmt.Errorf("unknown store type %q", storeType)
 }
@@ -5,11 +5,7 @@ import (
 	"github.com/gofrs/uuid"
 )
 
-// BattleShipNumber is the total number
-// of battleships in a single board
-const BattleShipNumber = 3
-
-// BattleShip lengths types
+// BattleShip lengths of the default rule set
 const (
 	DestroyerLength  = 4
 	BattleShipLength = 5
@@ -21,19 +17,25 @@ const (
 	Alive = false
 )
 
-// BattleShip has 2 types of battleships
-// with lengths of 4 and 5
+// BattleShip is a single ship of the fleet, its class
+// and length come from session rules
 type BattleShip struct {
-	ID         string `json:"id"`          // UUID
-	Length     int    `json:"length"`      // they must be either 4 or 5
+	ID         string `json:"id"`             // UUID
+	Name       string `json:"name,omitempty"` // ship class name
+	Length     int    `json:"length"`
 	IsVertical bool   `json:"is_vertical"` // indicates ship orientation
 	Cells      []Cell `json:"cells"`
 	IsDead     bool   `json:"is_dead"` // indicates if ships is dead or alive (true = Dead, false = Alive)
+
+	// Shape and Orientation describe polyomino ship, see orient
+	Shape       []Cell `json:"shape,omitempty"`
+	Orientation int    `json:"orientation,omitempty"`
 }
 
 // NewBattleShip creates new battleship struct
-func NewBattleShip(shipLength int) (*BattleShip, error) {
+func NewBattleShip(name string, shipLength int) (*BattleShip, error) {
 	ship := BattleShip{
+		Name:   name,
 		Length: shipLength,
 	}
 
@@ -54,20 +56,36 @@ func NewBattleShip(shipLength int) (*BattleShip, error) {
 
 // BuildBody adds head cell and rest of the cells depending on length
 func (b *BattleShip) BuildBody(headCell Cell) {
-	b.Cells = append(b.Cells, headCell)
+	b.Cells = append(b.Cells, b.BodyCells(headCell)...)
+}
+
+// BodyCells returns cells ship would take with given head cell. Head
+// of polyomino ship is the corner of its bounding box
+func (b *BattleShip) BodyCells(headCell Cell) []Cell {
+	if len(b.Shape) > 0 {
+		cells := orient(b.Shape, b.Orientation)
+		for i := range cells {
+			cells[i].X += headCell.X
+			cells[i].Y += headCell.Y
+		}
+		return cells
+	}
+
+	cells := []Cell{headCell}
 	for i := 1; i < b.Length; i++ {
 		if b.IsVertical {
-			b.Cells = append(b.Cells, Cell{
+			cells = append(cells, Cell{
 				X: headCell.X,
 				Y: headCell.Y + i,
 			})
 		} else {
-			b.Cells = append(b.Cells, Cell{
+			cells = append(cells, Cell{
 				X: headCell.X + i,
 				Y: headCell.Y,
 			})
 		}
 	}
+	return cells
 }
 
 func (b *BattleShip) GetDamageCount() int {
@@ -14,7 +14,7 @@ func printBoard(b *Board) {
 
 func TestFindRandomSpace(t *testing.T) {
 	for i := 0; i < 1000; i++ {
-		board, err := GenerateBoard(false)
+		board, err := GenerateBoard(false, DefaultRuleSet())
 		if err != nil {
 			t.Fatal("failed to create a board:", err)
 		}
@@ -34,3 +34,156 @@ func TestFindRandomSpace(t *testing.T) {
 		}
 	}
 }
+
+func TestGenerateBoardFleet(t *testing.T) {
+	for _, rules := range RuleSets() {
+		board, err := GenerateBoard(false, rules)
+		if err != nil {
+			t.Fatalf("%s: cannot generate board: %v", rules.Name, err)
+		}
+		if len(board.Battleships) != rules.FleetSize() {
+			t.Errorf("%s: expected %d ships, got %d", rules.Name, rules.FleetSize(), len(board.Battleships))
+		}
+
+		counts := map[string]int{}
+		for _, ship := range board.Battleships {
+			if len(ship.Cells) != ship.Length {
+				t.Errorf("%s: %s has %d cells instead of %d", rules.Name, ship.Name, len(ship.Cells), ship.Length)
+			}
+			counts[ship.Name]++
+		}
+		for _, class := range rules.Ships {
+			if counts[class.Name] != class.Count {
+				t.Errorf("%s: expected %d %s ships, got %d", rules.Name, class.Count, class.Name, counts[class.Name])
+			}
+		}
+	}
+}
+
+func TestCalculateShotStaysOnBoard(t *testing.T) {
+	for _, name := range []string{"quick", "large", "seabattle", "islands", "shapes"} {
+		rules, _ := RuleSetByName(name)
+		board, err := GenerateBoard(false, rules)
+		if err != nil {
+			t.Fatalf("%s: cannot generate board: %v", name, err)
+		}
+
+		for shots := 0; !board.IsFleetDestroyed(); shots++ {
+			if shots == rules.Width*rules.Height {
+				t.Fatalf("%s: fleet is not destroyed after shooting every cell", name)
+			}
+
+			shot := board.CalculateShot()
+			if shot == nil || !board.Contains(*shot) || board.HasShot(*shot) || board.IsTerrain(*shot) {
+				t.Fatalf("%s: invalid computer shot %v", name, shot)
+			}
+			if rules.NoTouching && touchesShip(board.GetDeadShips(), *shot) {
+				t.Fatalf("%s: computer shot %v next to sunk ship", name, *shot)
+			}
+			if _, shipID := board.RegisterShot(*shot); shipID != "" {
+				board.MarkShipIfDead(shipID)
+			}
+		}
+	}
+}
+
+func touchesShip(ships []BattleShip, cell Cell) bool {
+	for _, ship := range ships {
+		for _, shipCell := range ship.Cells {
+			for _, neighbour := range shipCell.Neighbours() {
+				if neighbour.Compare(&cell) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func TestNoTouchingPlacement(t *testing.T) {
+	rules, _ := RuleSetByName("seabattle")
+	for i := 0; i < 200; i++ {
+		board, err := GenerateBoard(false, rules)
+		if err != nil {
+			t.Fatal("failed to create a board:", err)
+		}
+
+		for j, ship := range board.Battleships {
+			others := []BattleShip{}
+			for k, other := range board.Battleships {
+				if k != j {
+					others = append(others, *other)
+				}
+			}
+			for _, cell := range ship.Cells {
+				if touchesShip(others, cell) {
+					t.Fatalf("ship %s at %v touches another ship", ship.Name, ship.Cells)
+				}
+			}
+		}
+	}
+
+	board := NewBoard(false)
+	board.NoTouching = true
+	board.Battleships = []*BattleShip{{Length: 2, Cells: []Cell{{X: 0, Y: 0}, {X: 1, Y: 0}}}}
+	if board.CanPlace([]Cell{{X: 2, Y: 1}}) {
+		t.Error("expected diagonally touching ship to be rejected")
+	}
+	if !board.CanPlace([]Cell{{X: 3, Y: 0}}) {
+		t.Error("expected separated ship to be accepted")
+	}
+}
+
+func TestTerrain(t *testing.T) {
+	rules, _ := RuleSetByName("islands")
+	for i := 0; i < 100; i++ {
+		board, err := GenerateBoard(false, rules)
+		if err != nil {
+			t.Fatal("failed to create a board:", err)
+		}
+
+		terrain := CellMap{}
+		for _, cell := range board.Terrain {
+			if terrain[cell.X][cell.Y] {
+				t.Fatalf("terrain at (%d, %d) is duplicated", cell.X, cell.Y)
+			}
+			terrain.add(cell)
+		}
+		if len(terrain) == 0 || len(board.Terrain) != len(rules.Terrain)+rules.RandomTerrain {
+			t.Fatalf("expected %d terrain cells, got %d", len(rules.Terrain)+rules.RandomTerrain, len(board.Terrain))
+		}
+
+		for _, ship := range board.Battleships {
+			for _, cell := range ship.Cells {
+				if terrain[cell.X][cell.Y] {
+					t.Fatalf("%s is placed on terrain at (%d, %d)", ship.Name, cell.X, cell.Y)
+				}
+			}
+		}
+	}
+}
+
+func TestHiddenSinksTargeting(t *testing.T) {
+	board := NewBoard(false)
+	board.HiddenSinks = true
+
+	destroyer, _ := NewBattleShip("destroyer", 2)
+	destroyer.IsVertical = false
+	destroyer.BuildBody(Cell{X: 2, Y: 3})
+	battleship, _ := NewBattleShip("battleship", 5)
+	battleship.IsVertical = true
+	battleship.BuildBody(Cell{X: 9, Y: 0})
+	board.Battleships = []*BattleShip{destroyer, battleship}
+	for _, cell := range destroyer.Cells {
+		board.RegisterShot(cell)
+	}
+	board.MarkShipIfDead(destroyer.ID)
+
+	// sunk ship is not known, so the line of hits is followed up
+	for i := 0; i < 20; i++ {
+		shot := board.CalculateShot()
+		if shot == nil || shot.Y != 3 || (shot.X != 1 && shot.X != 4) {
+			t.Fatalf("expected shot going on with the hits, got %v", shot)
+		}
+	}
+}
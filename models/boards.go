@@ -1,71 +1,40 @@
 package models
 
 import (
-	"errors"
-
 	"github.com/billyboar/battleships/helpers"
 )
 
-// BoardRow - Board has predifined 10 rows
+// BoardRow is the size of default square board
 const BoardRow = 10
 
 // Board will contain battleships
 type Board struct {
 	IsComputer  bool          `json:"is_computer"` // True: if board is for computer, False if not
+	Width       int           `json:"width"`
+	Height      int           `json:"height"`
+	NoTouching  bool          `json:"no_touching,omitempty"`  // ships may not touch, see RuleSet
+	HiddenSinks bool          `json:"hidden_sinks,omitempty"` // shooter is not told about sunk ships
+	Terrain     []Cell        `json:"terrain,omitempty"`      // cells taken by islands and rocks
 	Battleships []*BattleShip `json:"battleships"`
 	MissedShots []Cell        `json:"missed_shots"`
+	Shots       []Cell        `json:"shots"` // every cell fired at, in order
+	SonarScans  []SonarScan   `json:"sonar_scans,omitempty"`
 }
 
-// NewBoard creates simple board
+// NewBoard creates simple board of default size
 func NewBoard(isComputer bool) *Board {
 	board := Board{
 		IsComputer: isComputer,
+		Width:      BoardRow,
+		Height:     BoardRow,
 	}
 
 	return &board
 }
 
-// GenerateBoard creates new board with 2 destroyer ships and single battleship
-func GenerateBoard(isComputer bool) (*Board, error) {
-	board := NewBoard(isComputer)
-	destroyerOne, err := NewBattleShip(DestroyerLength)
-	if err != nil {
-		return nil, err
-	}
-	destroyerTwo, err := NewBattleShip(DestroyerLength)
-	if err != nil {
-		return nil, err
-	}
-	battleShip, err := NewBattleShip(BattleShipLength)
-	if err != nil {
-		return nil, err
-	}
-
-	if err := board.AddNewShip(destroyerOne); err != nil {
-		return nil, err
-	}
-	if err := board.AddNewShip(destroyerTwo); err != nil {
-		return nil, err
-	}
-	if err := board.AddNewShip(battleShip); err != nil {
-		return nil, err
-	}
-
-	return board, nil
-}
-
-// AddNewShip adds new ships to board
-func (b *Board) AddNewShip(ship *BattleShip) error {
-	if len(b.Battleships) == BattleShipNumber {
-		return errors.New("board has full ships")
-	}
-
-	headCell := b.FindRandomHeadCell(ship)
-	ship.BuildBody(headCell)
-
-	b.Battleships = append(b.Battleships, ship)
-
-	return nil
+// Contains reports if cell lies within the board
+func (b *Board) Contains(c Cell) bool {
+	return c.X >= 0 && c.X < b.Width && c.Y >= 0 && c.Y < b.Height
 }
 
 // FindShip returns battleship with given ID
@@ -78,85 +47,71 @@ func (b *Board) FindShip(shipID string) *BattleShip {
 	return nil
 }
 
-// FindRandomHeadCell finds head cell for a ship randomly satisfying
-// condition that ships don't overlap
-func (b *Board) FindRandomHeadCell(ship *BattleShip) Cell {
-	// 70 total variants for vertical/horizontal battleships
-	// 80 total variants for vertical/horizontal destroyers
-
-	var xLimit, yLimit int
-
-	if ship.IsVertical {
-		xLimit = BoardRow - 1
-		yLimit = BoardRow - ship.Length
-	} else {
-		xLimit = BoardRow - ship.Length
-		yLimit = BoardRow - 1
-	}
-
-	var possibleHeadCells []Cell
-	for x := 0; x <= xLimit; x++ {
-		for y := 0; y <= yLimit; y++ {
-			isValidCell := true
-			for _, shipOnBoard := range b.Battleships {
-				headCell := shipOnBoard.Cells[0]
-
-				if ship.IsVertical && shipOnBoard.IsVertical {
-					if x == headCell.X &&
-						((headCell.Y > y && headCell.Y-y < ship.Length) ||
-							(headCell.Y < y && y-headCell.Y < shipOnBoard.Length) ||
-							headCell.Y == y) {
-						isValidCell = false
-						break
-					}
-				}
-
-				if ship.IsVertical && !shipOnBoard.IsVertical {
-					if (x >= headCell.X && x <= headCell.X+shipOnBoard.Length-1) &&
-						((headCell.Y > y && headCell.Y-y < ship.Length) ||
-							(headCell.Y < y && y-headCell.Y < shipOnBoard.Length) ||
-							headCell.Y == y) {
-						isValidCell = false
-						break
-					}
-				}
+// shipCellAt returns ship cell lying at given cell, nil for water
+func (b *Board) shipCellAt(c Cell) *Cell {
+	for _, battleship := range b.Battleships {
+		for i := range battleship.Cells {
+			if battleship.Cells[i].Compare(&c) {
+				return &battleship.Cells[i]
+			}
+		}
+	}
+	return nil
+}
 
-				if !ship.IsVertical && !shipOnBoard.IsVertical {
-					if y == headCell.Y &&
-						((headCell.X > x && headCell.X-x < ship.Length) ||
-							(headCell.X < x && x-headCell.X < shipOnBoard.Length) ||
-							headCell.X == x) {
-						isValidCell = false
-						break
-					}
-				}
+// CanPlace reports if ship with given cells can be added to the board
+func (b *Board) CanPlace(cells []Cell) bool {
+	return b.fits(b.blockedCells(), cells)
+}
 
-				if !ship.IsVertical && shipOnBoard.IsVertical {
-					if (y >= headCell.Y && y <= headCell.Y+shipOnBoard.Length-1) &&
-						((headCell.X > x && headCell.X-x < ship.Length) ||
-							(headCell.X < x && x-headCell.X < shipOnBoard.Length) ||
-							headCell.X == x) {
-						isValidCell = false
-						break
-					}
+// blockedCells returns cells new ship may not take, these are terrain,
+// cells of placed ships and with NoTouching rule every cell around them
+func (b *Board) blockedCells() CellMap {
+	blocked := CellMap{}
+	for _, cell := range b.Terrain {
+		blocked.add(cell)
+	}
+	for _, ship := range b.Battleships {
+		for _, cell := range ship.Cells {
+			blocked.add(cell)
+			if b.NoTouching {
+				for _, neighbour := range cell.Neighbours() {
+					blocked.add(neighbour)
 				}
 			}
-			if isValidCell {
-				possibleHeadCells = append(possibleHeadCells, Cell{
-					X: x,
-					Y: y,
-				})
-			}
 		}
 	}
+	return blocked
+}
+
+func (b *Board) fits(blocked CellMap, cells []Cell) bool {
+	for _, cell := range cells {
+		if !b.Contains(cell) || blocked[cell.X][cell.Y] {
+			return false
+		}
+	}
+	return true
+}
 
-	randomCellNumber := helpers.GenerateRandomInt(len(possibleHeadCells))
-	return possibleHeadCells[randomCellNumber]
+// HasShot reports if cell was already fired at
+func (b *Board) HasShot(cell Cell) bool {
+	for _, shot := range b.Shots {
+		if shot.Compare(&cell) {
+			return true
+		}
+	}
+	return false
 }
 
 // RegisterShot marks cell as dead if shot cell equals
-// to any cell of any battleships
+// to any cell of any battleships. Repeated shots are not
+// recorded again
 func (b *Board) RegisterShot(shot Cell) (shotStatus bool, shipID string) {
+	isRepeated := b.HasShot(shot)
+	if !isRepeated {
+		b.Shots = append(b.Shots, Cell{X: shot.X, Y: shot.Y})
+	}
+
 	for _, battleship := range b.Battleships {
 		for cellNum, cell := range battleship.Cells {
 			if cell.Compare(&shot) {
@@ -169,7 +124,7 @@ func (b *Board) RegisterShot(shot Cell) (shotStatus bool, shipID string) {
 		}
 	}
 
-	if !shotStatus {
+	if !shotStatus && !isRepeated {
 		b.MissedShots = append(b.MissedShots, shot)
 	}
 
@@ -212,30 +167,89 @@ func (b *Board) GetDeadShips() []BattleShip {
 	return deadShips
 }
 
+// IsFleetDestroyed reports if every ship on the board is dead
+func (b *Board) IsFleetDestroyed() bool {
+	if len(b.Battleships) == 0 {
+		return false
+	}
+
+	for _, battleship := range b.Battleships {
+		if !battleship.IsDead {
+			return false
+		}
+	}
+	return true
+}
+
 type CellMap map[int]map[int]bool
 
+func (m CellMap) add(cell Cell) {
+	if m[cell.X] == nil {
+		m[cell.X] = make(map[int]bool)
+	}
+	m[cell.X][cell.Y] = true
+}
+
+// CalculateShot picks next computer shot at the board, nil when
+// every cell was already fired at
 func (b *Board) CalculateShot() *Cell {
+	return b.calculateShot(CellMap{})
+}
+
+// planSalvo plans up to n distinct shots picked by the strategy. Results
+// of the salvo are unknown while planning, so planned shots are only kept
+// apart and never followed up
+func (b *Board) planSalvo(strategy ShotStrategy, n int) []Cell {
+	planned := CellMap{}
+	var shots []Cell
+	for len(shots) < n {
+		shot := strategy.Shot(b, planned)
+		if shot == nil {
+			break
+		}
+		planned.add(*shot)
+		shots = append(shots, *shot)
+	}
+	return shots
+}
+
+// calculateShot picks next shot skipping planned cells as well, cells
+// most of possible ship positions go through are preferred
+func (b *Board) calculateShot(planned CellMap) *Cell {
+	if shot := b.densityShot(planned); shot != nil {
+		return shot
+	}
+	return b.huntTargetShot(planned)
+}
+
+// huntTargetShot fires at random until it hits and then probes cells
+// around the hits. It is used when no ship position fits the shots
+func (b *Board) huntTargetShot(planned CellMap) *Cell {
 	possibleCells := []Cell{}
-	missedShotsMap := CellMap{}
+	missedShotsMap := b.knownCells(planned)
+	sonarCellsMap := b.applySonar(missedShotsMap)
 
-	// populate missedShotsMap
-	for _, missedShot := range b.MissedShots {
-		if missedShotsMap[missedShot.X] == nil {
-			missedShotsMap[missedShot.X] = make(map[int]bool)
+	// without sunk ships announced every hit may belong to a ship afloat
+	if b.HiddenSinks {
+		possibleCells = b.hiddenTargets(missedShotsMap)
+		if len(possibleCells) > 0 {
+			return &possibleCells[helpers.GenerateRandomInt(len(possibleCells))]
 		}
-		missedShotsMap[missedShot.X][missedShot.Y] = true
+	}
+
+	// hidden sinks do not tell which ship was hit either
+	battleShips := b.Battleships
+	if b.HiddenSinks {
+		battleShips = nil
 	}
 
 	woundedCellsMap := CellMap{}
 	woundedShips := []*BattleShip{}
-	for _, battleShip := range b.Battleships {
+	for _, battleShip := range battleShips {
 		if !battleShip.IsDead {
 			woundedCells := battleShip.GetDamagedCells()
 			for _, woundedCell := range woundedCells {
-				if woundedCellsMap[woundedCell.X] == nil {
-					woundedCellsMap[woundedCell.X] = make(map[int]bool)
-				}
-				woundedCellsMap[woundedCell.X][woundedCell.Y] = true
+				woundedCellsMap.add(woundedCell)
 			}
 
 			// wounded yet not dead ships
@@ -246,89 +260,211 @@ func (b *Board) CalculateShot() *Cell {
 		}
 	}
 
-	if len(woundedShips) == 0 {
-		//when computer hasn't wounded any ships
-		for x := 0; x < BoardRow; x++ {
-			for y := 0; y < BoardRow; y++ {
-				if !missedShotsMap[x][y] && !woundedCellsMap[x][y] {
-					possibleCells = append(possibleCells, Cell{
-						X: x,
-						Y: y,
-					})
+	// calculate shots on wounded yet not dead ships
+	for _, woundedShip := range woundedShips {
+		woundedCells := woundedShip.GetDamagedCells()
+		if len(woundedShip.Shape) > 0 {
+			// polyomino ship may go on from any of its damaged cells
+			possibleCells = []Cell{}
+			for _, woundedCell := range woundedCells {
+				possibleCells = append(possibleCells, b.checkAllSides(missedShotsMap, woundedCellsMap, woundedCell)...)
+			}
+		} else if woundedShip.GetDamageCount() == 1 {
+			// when ship is hit only once
+			possibleCells = b.checkAllSides(missedShotsMap, woundedCellsMap, woundedCells[0])
+		} else {
+			// when ship is hit multiple times
+			// damaged cells will adjacent all the time
+			// since we are only shooting adjacent cells when ship is
+			// hit only once.
+			lastWoundedCell := woundedCells[len(woundedCells)-1]
+			if woundedShip.IsVertical {
+				possibleCells = b.checkVerticalCells(missedShotsMap, woundedCellsMap, lastWoundedCell)
+				if len(possibleCells) == 0 {
+					possibleCells = b.checkVerticalCells(missedShotsMap, woundedCellsMap, woundedCells[0])
+				}
+			} else {
+				possibleCells = b.checkHorizontalCells(missedShotsMap, woundedCellsMap, lastWoundedCell)
+				if len(possibleCells) == 0 {
+					possibleCells = b.checkHorizontalCells(missedShotsMap, woundedCellsMap, woundedCells[0])
 				}
 			}
 		}
 
-		return &possibleCells[helpers.GenerateRandomInt(len(possibleCells))]
+		// planned shots may already cover the ship, next one is tried then
+		if len(possibleCells) > 0 {
+			return &possibleCells[helpers.GenerateRandomInt(len(possibleCells))]
+		}
 	}
 
-	// calculate shots on wounded yet not dead ships
-	for _, woundedShip := range woundedShips {
-		woundedCells := woundedShip.GetDamagedCells()
-		if woundedShip.GetDamageCount() == 1 {
-			// when ship is hit only once
-			possibleCells := checkAllSides(missedShotsMap, woundedCellsMap, woundedCells[0])
-			return &possibleCells[helpers.GenerateRandomInt(len(possibleCells))]
+	// when there is no wounded ship to finish, areas where sonar
+	// found ships are searched first
+	possibleCells = []Cell{}
+	sonarCells := []Cell{}
+	for x := 0; x < b.Width; x++ {
+		for y := 0; y < b.Height; y++ {
+			if !missedShotsMap[x][y] && !woundedCellsMap[x][y] {
+				possibleCells = append(possibleCells, Cell{
+					X: x,
+					Y: y,
+				})
+				if sonarCellsMap[x][y] {
+					sonarCells = append(sonarCells, Cell{X: x, Y: y})
+				}
+			}
+		}
+	}
+	if len(possibleCells) == 0 {
+		return nil
+	}
+	if len(sonarCells) > 0 {
+		possibleCells = sonarCells
+	}
+
+	return &possibleCells[helpers.GenerateRandomInt(len(possibleCells))]
+}
+
+// knownCells returns cells shooter knows there is nothing left to hit,
+// those are fired at or planned ones and terrain
+func (b *Board) knownCells(planned CellMap) CellMap {
+	known := CellMap{}
+
+	// every fired cell is included, so cells of already destroyed
+	// ships are not shot again either, terrain cannot be shot at all
+	for _, shots := range [][]Cell{b.MissedShots, b.Shots, b.Terrain} {
+		for _, shot := range shots {
+			known.add(shot)
 		}
+	}
+	for x, column := range planned {
+		for y := range column {
+			known.add(Cell{X: x, Y: y})
+		}
+	}
 
-		// when ship is hit multiple times
-		// damaged cells will adjacent all the time
-		// since we are only shooting adjacent cells when ship is
-		// hit only once.
-		lastWoundedCell := woundedCells[len(woundedCells)-1]
-		if woundedShip.IsVertical {
-			possibleCells = checkVerticalCells(missedShotsMap, woundedCellsMap, lastWoundedCell)
-			if len(possibleCells) == 0 {
-				possibleCells = checkVerticalCells(missedShotsMap, woundedCellsMap, woundedCells[0])
+	// ships cannot touch sunk ones, so cells around them are empty
+	if b.NoTouching && !b.HiddenSinks {
+		for _, deadShip := range b.GetDeadShips() {
+			for _, cell := range deadShip.Cells {
+				for _, neighbour := range cell.Neighbours() {
+					known.add(neighbour)
+				}
 			}
-		} else {
-			possibleCells = checkHorizontalCells(missedShotsMap, woundedCellsMap, lastWoundedCell)
-			if len(possibleCells) == 0 {
-				possibleCells = checkHorizontalCells(missedShotsMap, woundedCellsMap, woundedCells[0])
+		}
+	}
+	return known
+}
+
+// applySonar adds areas where sonar found nothing to known cells,
+// as they were swept clean, and returns areas where it found ships
+func (b *Board) applySonar(known CellMap) CellMap {
+	found := CellMap{}
+	for _, scan := range b.SonarScans {
+		for _, cell := range scan.Area() {
+			if scan.ShipCells == 0 {
+				known.add(cell)
+			} else {
+				found.add(cell)
 			}
 		}
+	}
+	return found
+}
 
-		return &possibleCells[helpers.GenerateRandomInt(len(possibleCells))]
+// Hits returns cells fired at which hit a ship, in order
+func (b *Board) Hits() []Cell {
+	missed := CellMap{}
+	for _, shot := range b.MissedShots {
+		missed.add(shot)
 	}
 
-	return nil
+	hits := []Cell{}
+	for _, shot := range b.Shots {
+		if !missed[shot.X][shot.Y] {
+			hits = append(hits, shot)
+		}
+	}
+	return hits
+}
+
+// hiddenTargets returns unknown cells next to hits when sunk ships are
+// not announced, cells going on with a line of hits come first
+func (b *Board) hiddenTargets(known CellMap) []Cell {
+	hits := CellMap{}
+	for _, hit := range b.Hits() {
+		hits.add(hit)
+	}
+
+	var lineCells, sideCells []Cell
+	for _, hit := range b.Hits() {
+		for _, side := range hit.Sides() {
+			if !b.Contains(side) || known[side.X][side.Y] {
+				continue
+			}
+			sideCells = append(sideCells, side)
+
+			behind := Cell{X: 2*hit.X - side.X, Y: 2*hit.Y - side.Y}
+			if hits[behind.X][behind.Y] {
+				lineCells = append(lineCells, side)
+			}
+		}
+	}
+
+	if len(lineCells) > 0 {
+		return lineCells
+	}
+	return sideCells
+}
+
+// hasTargets reports if shooter knows of a hit ship which may be afloat
+func (b *Board) hasTargets() bool {
+	if b.HiddenSinks {
+		return len(b.hiddenTargets(b.knownCells(CellMap{}))) > 0
+	}
+
+	for _, ship := range b.Battleships {
+		if !ship.IsDead && ship.GetDamageCount() > 0 {
+			return true
+		}
+	}
+	return false
 }
 
-func checkAllSides(missedShotsMap, woundedCellsMap map[int]map[int]bool, currentCell Cell) []Cell {
-	possibleVerticalCells := checkVerticalCells(missedShotsMap, woundedCellsMap, currentCell)
-	possibleHorizontalCells := checkHorizontalCells(missedShotsMap, woundedCellsMap, currentCell)
+func (b *Board) checkAllSides(missedShotsMap, woundedCellsMap map[int]map[int]bool, currentCell Cell) []Cell {
+	possibleVerticalCells := b.checkVerticalCells(missedShotsMap, woundedCellsMap, currentCell)
+	possibleHorizontalCells := b.checkHorizontalCells(missedShotsMap, woundedCellsMap, currentCell)
 
 	return append(possibleVerticalCells, possibleHorizontalCells...)
 }
 
-func checkVerticalCells(missedShotsMap, woundedCellsMap map[int]map[int]bool, currentCell Cell) []Cell {
+func (b *Board) checkVerticalCells(missedShotsMap, woundedCellsMap map[int]map[int]bool, currentCell Cell) []Cell {
 	possibleCells := []Cell{}
 	// checking above cell
-	if cell := checkAboveCell(missedShotsMap, woundedCellsMap, currentCell); cell != nil {
+	if cell := b.checkAboveCell(missedShotsMap, woundedCellsMap, currentCell); cell != nil {
 		possibleCells = append(possibleCells, *cell)
 	}
 
 	// checking below cell
-	if cell := checkBelowCell(missedShotsMap, woundedCellsMap, currentCell); cell != nil {
+	if cell := b.checkBelowCell(missedShotsMap, woundedCellsMap, currentCell); cell != nil {
 		possibleCells = append(possibleCells, *cell)
 	}
 	return possibleCells
 }
 
-func checkHorizontalCells(missedShotsMap, woundedCellsMap map[int]map[int]bool, currentCell Cell) []Cell {
+func (b *Board) checkHorizontalCells(missedShotsMap, woundedCellsMap map[int]map[int]bool, currentCell Cell) []Cell {
 	possibleCells := []Cell{}
-	if cell := checkLeftCell(missedShotsMap, woundedCellsMap, currentCell); cell != nil {
+	if cell := b.checkLeftCell(missedShotsMap, woundedCellsMap, currentCell); cell != nil {
 		possibleCells = append(possibleCells, *cell)
 	}
 
 	// checking right cell
-	if cell := checkRightCell(missedShotsMap, woundedCellsMap, currentCell); cell != nil {
+	if cell := b.checkRightCell(missedShotsMap, woundedCellsMap, currentCell); cell != nil {
 		possibleCells = append(possibleCells, *cell)
 	}
 	return possibleCells
 }
 
-func checkAboveCell(missedShotsMap, woundedCellsMap map[int]map[int]bool, currentCell Cell) *Cell {
+func (b *Board) checkAboveCell(missedShotsMap, woundedCellsMap map[int]map[int]bool, currentCell Cell) *Cell {
 	tempCoordinate := currentCell.Y - 1
 	if tempCoordinate >= 0 && !woundedCellsMap[currentCell.X][tempCoordinate] && !missedShotsMap[currentCell.X][tempCoordinate] {
 		return &Cell{
@@ -339,9 +475,9 @@ func checkAboveCell(missedShotsMap, woundedCellsMap map[int]map[int]bool, curren
 	return nil
 }
 
-func checkBelowCell(missedShotsMap, woundedCellsMap map[int]map[int]bool, currentCell Cell) *Cell {
+func (b *Board) checkBelowCell(missedShotsMap, woundedCellsMap map[int]map[int]bool, currentCell Cell) *Cell {
 	tempCoordinate := currentCell.Y + 1
-	if tempCoordinate < BoardRow && !woundedCellsMap[currentCell.X][tempCoordinate] && !missedShotsMap[currentCell.X][tempCoordinate] {
+	if tempCoordinate < b.Height && !woundedCellsMap[currentCell.X][tempCoordinate] && !missedShotsMap[currentCell.X][tempCoordinate] {
 		return &Cell{
 			X: currentCell.X,
 			Y: tempCoordinate,
@@ -350,7 +486,7 @@ func checkBelowCell(missedShotsMap, woundedCellsMap map[int]map[int]bool, curren
 	return nil
 }
 
-func checkLeftCell(missedShotsMap, woundedCellsMap map[int]map[int]bool, currentCell Cell) *Cell {
+func (b *Board) checkLeftCell(missedShotsMap, woundedCellsMap map[int]map[int]bool, currentCell Cell) *Cell {
 	tempCoordinate := currentCell.X - 1
 	if tempCoordinate >= 0 && !woundedCellsMap[tempCoordinate][currentCell.Y] && !missedShotsMap[tempCoordinate][currentCell.Y] {
 		return &Cell{
@@ -361,9 +497,9 @@ func checkLeftCell(missedShotsMap, woundedCellsMap map[int]map[int]bool, current
 	return nil
 }
 
-func checkRightCell(missedShotsMap, woundedCellsMap map[int]map[int]bool, currentCell Cell) *Cell {
+func (b *Board) checkRightCell(missedShotsMap, woundedCellsMap map[int]map[int]bool, currentCell Cell) *Cell {
 	tempCoordinate := currentCell.X + 1
-	if tempCoordinate < BoardRow && !woundedCellsMap[tempCoordinate][currentCell.Y] && !missedShotsMap[tempCoordinate][currentCell.Y] {
+	if tempCoordinate < b.Width && !woundedCellsMap[tempCoordinate][currentCell.Y] && !missedShotsMap[tempCoordinate][currentCell.Y] {
 		return &Cell{
 			X: tempCoordinate,
 			Y: currentCell.Y,
@@ -7,6 +7,30 @@ type Cell struct {
 	IsDead bool `json:"is_dead"` // indicates single ship cell status (True = Dead, false = Alive)
 }
 
+// Neighbours returns cells around the cell, including diagonal ones.
+// Cells outside of the board are not filtered out
+func (c Cell) Neighbours() []Cell {
+	cells := make([]Cell, 0, 8)
+	for dx := -1; dx <= 1; dx++ {
+		for dy := -1; dy <= 1; dy++ {
+			if dx != 0 || dy != 0 {
+				cells = append(cells, Cell{X: c.X + dx, Y: c.Y + dy})
+			}
+		}
+	}
+	return cells
+}
+
+// Sides returns cells sharing a side with the cell
+func (c Cell) Sides() []Cell {
+	return []Cell{
+		{X: c.X, Y: c.Y - 1},
+		{X: c.X + 1, Y: c.Y},
+		{X: c.X, Y: c.Y + 1},
+		{X: c.X - 1, Y: c.Y},
+	}
+}
+
 // Compare compares if two cells has same
 // coordinates
 func (c Cell) Compare(input *Cell) bool {
@@ -15,10 +39,3 @@ func (c Cell) Compare(input *Cell) bool {
 	}
 	return false
 }
-
-func (c Cell) IsValid() bool {
-	if c.X > BoardRow-1 || c.X < 0 || c.Y > BoardRow-1 || c.Y < 0 {
-		return false
-	}
-	return true
-}
@@ -0,0 +1,150 @@
+package db
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/billyboar/battleships/models"
+)
+
+// ErrNotArchived is returned when archive has no such session
+var ErrNotArchived = errors.New("session is not archived")
+
+// Archive keeps expired sessions as gzip compressed JSON files,
+// one file per session
+type Archive struct {
+	dir string
+}
+
+// archivedSession is the content of a single archive file
+type archivedSession struct {
+	Summary    *models.SessionSummary `json:"summary"`
+	Events     []logEvent             `json:"events"`
+	ArchivedAt time.Time              `json:"archived_at"`
+}
+
+// NewArchive creates archive inside given directory. Directory is
+// created on first save
+func NewArchive(dir string) *Archive {
+	return &Archive{
+		dir: dir,
+	}
+}
+
+func (a *Archive) path(sessionID string) string {
+	return filepath.Join(a.dir, sessionID+".json.gz")
+}
+
+// Save writes session events into archive replacing previous copy
+func (a *Archive) Save(summary *models.SessionSummary, events []*models.Event) error {
+	archived := archivedSession{
+		Summary:    summary,
+		Events:     make([]logEvent, len(events)),
+		ArchivedAt: time.Now(),
+	}
+	for i, event := range events {
+		data, err := event.EncodeData()
+		if err != nil {
+			return err
+		}
+
+		archived.Events[i] = logEvent{
+			EventType:     event.EventType,
+			Data:          data,
+			CreatedAt:     event.CreatedAt,
+			SchemaVersion: event.SchemaVersion,
+		}
+	}
+
+	if err := os.MkdirAll(a.dir, 0755); err != nil {
+		return err
+	}
+
+	path := a.path(summary.ID)
+	tmpFile, err := ioutil.TempFile(a.dir, filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+
+	writer := gzip.NewWriter(tmpFile)
+	if err := json.NewEncoder(writer).Encode(archived); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpFile.Name(), path)
+}
+
+// Load reads archived session events and the time they were archived
+func (a *Archive) Load(sessionID string) ([]*models.Event, time.Time, error) {
+	file, err := os.Open(a.path(sessionID))
+	if os.IsNotExist(err) {
+		return nil, time.Time{}, ErrNotArchived
+	}
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	defer file.Close()
+
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	defer reader.Close()
+
+	var archived archivedSession
+	if err := json.NewDecoder(reader).Decode(&archived); err != nil {
+		return nil, time.Time{}, err
+	}
+
+	events := make([]*models.Event, len(archived.Events))
+	for i, event := range archived.Events {
+		events[i] = event.toEvent(sessionID)
+		events[i].Sequence = i + 1
+	}
+
+	return events, archived.ArchivedAt, nil
+}
+
+// Remove deletes session from archive
+func (a *Archive) Remove(sessionID string) error {
+	if err := os.Remove(a.path(sessionID)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// RestoreSession moves archived session back into live store. It fails
+// with ErrVersionConflict when session is still live
+func RestoreSession(store EventStore, archive *Archive, sessionID string) error {
+	events, archivedAt, err := archive.Load(sessionID)
+	if err != nil {
+		return err
+	}
+
+	// restore event refreshes last activity, so session is not
+	// expired again right away
+	events = append(events, models.CreateRestoreSessionEvent(sessionID, archivedAt))
+	if err := store.AppendEvents(sessionID, 0, events...); err != nil {
+		return err
+	}
+
+	return archive.Remove(sessionID)
+}
@@ -0,0 +1,127 @@
+package db
+
+import (
+	"log"
+	"time"
+
+	"github.com/billyboar/battleships/config"
+	"github.com/billyboar/battleships/models"
+)
+
+// compacter is implemented by stores which keep space of deleted
+// sessions until they are compacted
+type compacter interface {
+	Compact() error
+}
+
+// Cleaner archives expired sessions and removes them from live store
+type Cleaner struct {
+	store       EventStore
+	archive     *Archive
+	idleTTL     time.Duration
+	finishedTTL time.Duration
+}
+
+// NewCleaner creates cleaner using TTLs from config
+func NewCleaner(store EventStore, archive *Archive, conf *config.Config) *Cleaner {
+	return &Cleaner{
+		store:       store,
+		archive:     archive,
+		idleTTL:     conf.IdleSessionTTL,
+		finishedTTL: conf.FinishedSessionTTL,
+	}
+}
+
+// IsExpired reports if session has had no activity for longer than
+// its TTL. Zero TTL never expires sessions
+func (c *Cleaner) IsExpired(summary *models.SessionSummary, now time.Time) bool {
+	ttl := c.idleTTL
+	if summary.Status.IsFinished() {
+		ttl = c.finishedTTL
+	}
+
+	return ttl > 0 && now.Sub(summary.LastActivity) > ttl
+}
+
+// Run archives and deletes every session expired at given time
+// and returns the number of archived sessions. Store is compacted
+// afterwards when it supports it
+func (c *Cleaner) Run(now time.Time) (int, error) {
+	summaries, err := c.store.ListSessions()
+	if err != nil {
+		return 0, err
+	}
+
+	archived := 0
+	for _, summary := range summaries {
+		if !c.IsExpired(summary, now) {
+			continue
+		}
+
+		err := c.archiveSession(summary)
+		if err == ErrVersionConflict {
+			continue
+		}
+		if err != nil {
+			log.Println("cannot archive session", summary.ID, err)
+			continue
+		}
+		archived++
+	}
+
+	if store, ok := c.store.(compacter); ok && archived > 0 {
+		if err := store.Compact(); err != nil {
+			return archived, err
+		}
+	}
+	return archived, nil
+}
+
+// archiveSession moves session into archive. Unfinished sessions keep
+// their status, so they can be played again once restored. Session
+// which got a move meanwhile stays live and ErrVersionConflict is
+// returned
+func (c *Cleaner) archiveSession(summary *models.SessionSummary) error {
+	events, err := c.store.GetEvents(summary.ID)
+	if err != nil {
+		return err
+	}
+
+	if err := c.archive.Save(summary, events); err != nil {
+		return err
+	}
+
+	// session which got a move while it was archived is kept live
+	if err := c.store.DeleteSession(summary.ID, len(events)); err != nil {
+		c.archive.Remove(summary.ID)
+		return err
+	}
+	return nil
+}
+
+// Start runs cleanup every interval in background until
+// returned stop function is called
+func (c *Cleaner) Start(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				if archived, err := c.Run(now); err != nil {
+					log.Println("session cleanup failed:", err)
+				} else if archived > 0 {
+					log.Printf("archived %d expired sessions", archived)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+	}
+}
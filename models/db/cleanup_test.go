@@ -0,0 +1,121 @@
+package db
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/billyboar/battleships/config"
+	"github.com/billyboar/battleships/models"
+)
+
+func TestCleanerArchivesAndRestores(t *testing.T) {
+	dir, err := ioutil.TempDir("", "battleships")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store := NewMemoryStore()
+	archive := NewArchive(dir)
+	conf := &config.Config{
+		StrictReplay:       true,
+		IdleSessionTTL:     time.Hour,
+		FinishedSessionTTL: time.Minute,
+	}
+	cleaner := NewCleaner(store, archive, conf)
+
+	session, err := models.NewSession()
+	if err != nil {
+		t.Fatal("failed to create session:", err)
+	}
+	shot := models.Cell{X: 5, Y: 5}
+	err = store.AppendEvents(session.ID, 0,
+		models.CreateNewSessionEvent(session),
+		models.CreateShootEvent(session.ID, &shot, false, models.SideComputer),
+	)
+	if err != nil {
+		t.Fatal("failed to append events:", err)
+	}
+
+	if archived, err := cleaner.Run(time.Now().Add(30 * time.Minute)); err != nil || archived != 0 {
+		t.Fatalf("expected nothing to expire yet, archived %d: %v", archived, err)
+	}
+	if archived, err := cleaner.Run(time.Now().Add(2 * time.Hour)); err != nil || archived != 1 {
+		t.Fatalf("expected idle session to be archived, archived %d: %v", archived, err)
+	}
+	if events, _ := store.GetEvents(session.ID); len(events) != 0 {
+		t.Fatalf("expected archived session to leave live store, got %d events", len(events))
+	}
+
+	if err := RestoreSession(store, archive, session.ID); err != nil {
+		t.Fatal("failed to restore session:", err)
+	}
+	restored, err := LoadSession(store, session.ID, conf)
+	if err != nil {
+		t.Fatal("failed to load restored session:", err)
+	}
+	if wounds, missed := len(restored.Computer.GetAllShipWounds()), len(restored.Computer.MissedShots); wounds+missed != 1 {
+		t.Errorf("expected restored session to keep its shot, got %d wounds and %d misses", wounds, missed)
+	}
+	if restored.Status.IsFinished() {
+		t.Errorf("expected restored session to be playable, got %s", restored.Status)
+	}
+	if archived, err := cleaner.Run(time.Now().Add(30 * time.Minute)); err != nil || archived != 0 {
+		t.Errorf("expected restored session to stay live, archived %d: %v", archived, err)
+	}
+
+	if err := RestoreSession(store, archive, session.ID); err != ErrNotArchived {
+		t.Errorf("expected restored session to leave archive, got %v", err)
+	}
+}
+
+// racingStore appends a move right after events of session are read,
+// as if player made it while session was being archived
+type racingStore struct {
+	*MemoryStore
+	move *models.Event
+}
+
+func (store *racingStore) GetEvents(sessionID string) ([]*models.Event, error) {
+	events, err := store.MemoryStore.GetEvents(sessionID)
+	if store.move != nil {
+		store.MemoryStore.AppendEvent(sessionID, AnyVersion, store.move)
+		store.move = nil
+	}
+	return events, err
+}
+
+func TestCleanerKeepsSessionMovedWhileArchiving(t *testing.T) {
+	dir, err := ioutil.TempDir("", "battleships")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	session, err := models.NewSession()
+	if err != nil {
+		t.Fatal("failed to create session:", err)
+	}
+	shot := models.Cell{X: 5, Y: 5}
+	store := &racingStore{
+		MemoryStore: NewMemoryStore(),
+		move:        models.CreateShootEvent(session.ID, &shot, false, models.SideComputer),
+	}
+	if err := store.AppendEvent(session.ID, 0, models.CreateNewSessionEvent(session)); err != nil {
+		t.Fatal("failed to append event:", err)
+	}
+
+	archive := NewArchive(dir)
+	cleaner := NewCleaner(store, archive, &config.Config{IdleSessionTTL: time.Hour})
+	if archived, err := cleaner.Run(time.Now().Add(2 * time.Hour)); err != nil || archived != 0 {
+		t.Fatalf("expected session with new move to stay live, archived %d: %v", archived, err)
+	}
+	if events, _ := store.GetEvents(session.ID); len(events) != 2 {
+		t.Errorf("expected move made while archiving to be kept, got %d events", len(events))
+	}
+	if err := RestoreSession(store, archive, session.ID); err != ErrNotArchived {
+		t.Errorf("expected session to be removed from archive, got %v", err)
+	}
+}
@@ -0,0 +1,491 @@
+package db
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/billyboar/battleships/models"
+)
+
+// logFileName is the name of append-only log inside store directory
+const logFileName = "events.log"
+
+// snapshotDirName is the directory holding one snapshot file per session
+const snapshotDirName = "snapshots"
+
+// recordHeaderSize is the size of length and checksum prefix
+// written before each record payload
+const recordHeaderSize = 8
+
+// maxRecordSize protects recovery from allocating huge buffers
+// when length prefix itself is damaged
+const maxRecordSize = 16 << 20
+
+var errCorruptRecord = errors.New("corrupt log record")
+
+// logRecord is a single entry of the append-only log holding all events
+// of one append, so a batch is either fully recovered or dropped. Deleted
+// records are tombstones which drop the whole stream of the aggregate
+type logRecord struct {
+	AggregateID string     `json:"aggregate_id"`
+	Events      []logEvent `json:"events,omitempty"`
+	Deleted     bool       `json:"deleted,omitempty"`
+}
+
+type logEvent struct {
+	EventType     string    `json:"event_type"`
+	Data          string    `json:"data"`
+	CreatedAt     time.Time `json:"created_at"`
+	SchemaVersion int       `json:"schema_version,omitempty"`
+}
+
+func (e *logEvent) toEvent(aggregateID string) *models.Event {
+	return &models.Event{
+		AggregateID:   aggregateID,
+		Data:          e.Data,
+		EventType:     e.EventType,
+		CreatedAt:     e.CreatedAt,
+		SchemaVersion: e.SchemaVersion,
+	}
+}
+
+// eventPosition locates single event inside the log
+type eventPosition struct {
+	offset int64 // offset of the record
+	index  int   // index of event inside the record
+}
+
+// FileStore persists session streams into a single append-only log file
+// on local disk. Every record is fsync'd before append returns, and
+// positions of events are indexed in memory per AggregateID
+type FileStore struct {
+	mu    sync.RWMutex
+	dir   string
+	file  *os.File
+	size  int64
+	index map[string][]eventPosition
+
+	// summaries are derived from the log, they are rebuilt while
+	// recovering and never written separately
+	summaries map[string]*models.SessionSummary
+}
+
+// NewFileStore opens the log inside given directory, creating it when
+// missing. Torn record at the end of the log, left by a crash in the
+// middle of a write, is truncated. Corrupt record followed by other
+// records fails opening, cutting it off would lose them too
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(filepath.Join(dir, snapshotDirName), 0755); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(filepath.Join(dir, logFileName), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	store := &FileStore{
+		dir:   dir,
+		file:  file,
+		index: make(map[string][]eventPosition),
+
+		summaries: make(map[string]*models.SessionSummary),
+	}
+	if err := store.recover(); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return store, nil
+}
+
+// recover rebuilds index by scanning the whole log. Record which cannot
+// be read back is cut off only when it runs to the end of the log, as
+// only the last write can be torn
+func (store *FileStore) recover() error {
+	info, err := store.file.Stat()
+	if err != nil {
+		return err
+	}
+
+	var offset int64
+	for {
+		record, size, err := store.readRecord(offset)
+		if err == io.EOF {
+			break
+		}
+		if err == errCorruptRecord && offset+size < info.Size() {
+			return fmt.Errorf("%v at offset %d is followed by other records", err, offset)
+		}
+		if err == io.ErrUnexpectedEOF || err == errCorruptRecord {
+			if err := store.file.Truncate(offset); err != nil {
+				return err
+			}
+			if err := store.file.Sync(); err != nil {
+				return err
+			}
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		store.indexRecord(record, offset)
+		offset += size
+	}
+
+	store.size = offset
+	return nil
+}
+
+func (store *FileStore) indexRecord(record *logRecord, offset int64) {
+	if record.Deleted {
+		delete(store.index, record.AggregateID)
+		delete(store.summaries, record.AggregateID)
+		return
+	}
+
+	summary, ok := store.summaries[record.AggregateID]
+	if !ok {
+		summary = models.NewSessionSummary(record.AggregateID)
+		store.summaries[record.AggregateID] = summary
+	}
+
+	for i, event := range record.Events {
+		store.index[record.AggregateID] = append(store.index[record.AggregateID], eventPosition{
+			offset: offset,
+			index:  i,
+		})
+		summary.Update(event.toEvent(record.AggregateID))
+	}
+}
+
+// readRecord reads record at given offset and returns it with its total
+// size on disk. Size of corrupt record is taken from its length prefix
+func (store *FileStore) readRecord(offset int64) (*logRecord, int64, error) {
+	header := make([]byte, recordHeaderSize)
+	if n, err := store.file.ReadAt(header, offset); err != nil {
+		if err == io.EOF && n > 0 {
+			return nil, 0, io.ErrUnexpectedEOF
+		}
+		return nil, 0, err
+	}
+
+	length := binary.BigEndian.Uint32(header[:4])
+	checksum := binary.BigEndian.Uint32(header[4:])
+	size := recordHeaderSize + int64(length)
+	if length > maxRecordSize {
+		return nil, size, errCorruptRecord
+	}
+
+	payload := make([]byte, length)
+	if _, err := store.file.ReadAt(payload, offset+recordHeaderSize); err != nil {
+		if err == io.EOF {
+			return nil, 0, io.ErrUnexpectedEOF
+		}
+		return nil, 0, err
+	}
+	if crc32.ChecksumIEEE(payload) != checksum {
+		return nil, size, errCorruptRecord
+	}
+
+	var record logRecord
+	if err := json.Unmarshal(payload, &record); err != nil {
+		return nil, size, errCorruptRecord
+	}
+
+	return &record, size, nil
+}
+
+// encodeRecord returns record prefixed with its length and checksum
+func encodeRecord(record *logRecord) ([]byte, error) {
+	payload, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, recordHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(buf[:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(buf[4:recordHeaderSize], crc32.ChecksumIEEE(payload))
+	copy(buf[recordHeaderSize:], payload)
+	return buf, nil
+}
+
+// writeRecord appends record to the end of the log and fsyncs it
+func (store *FileStore) writeRecord(record *logRecord) (int64, error) {
+	buf, err := encodeRecord(record)
+	if err != nil {
+		return 0, err
+	}
+
+	offset := store.size
+	if _, err := store.file.WriteAt(buf, offset); err != nil {
+		// drop partially written bytes so next append starts clean
+		store.file.Truncate(offset)
+		return 0, err
+	}
+	if err := store.file.Sync(); err != nil {
+		return 0, err
+	}
+
+	store.size += int64(len(buf))
+	return offset, nil
+}
+
+// GetEvents returns all events for a session stream
+func (store *FileStore) GetEvents(sessionID string) ([]*models.Event, error) {
+	return store.getEventsFrom(sessionID, 0)
+}
+
+// GetEventsSince returns events following the snapshot version
+func (store *FileStore) GetEventsSince(snapshot *models.Snapshot) ([]*models.Event, error) {
+	return store.getEventsFrom(snapshot.SessionID, snapshot.Version)
+}
+
+func (store *FileStore) getEventsFrom(sessionID string, version int) ([]*models.Event, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	var record *logRecord
+	var recordOffset int64 = -1
+
+	positions := store.index[sessionID]
+	if version > len(positions) {
+		version = len(positions)
+	}
+	positions = positions[version:]
+
+	events := make([]*models.Event, len(positions))
+	for i, position := range positions {
+		// events of one batch share the record, read it only once
+		if position.offset != recordOffset {
+			var err error
+			record, _, err = store.readRecord(position.offset)
+			if err != nil {
+				return nil, err
+			}
+			recordOffset = position.offset
+		}
+
+		events[i] = record.Events[position.index].toEvent(record.AggregateID)
+		events[i].Sequence = version + i + 1
+	}
+
+	return events, nil
+}
+
+// AppendEvent adds new event to stream
+func (store *FileStore) AppendEvent(sessionID string, expectedVersion int, event *models.Event) error {
+	return store.AppendEvents(sessionID, expectedVersion, event)
+}
+
+// AppendEvents adds events to stream as a single log record
+func (store *FileStore) AppendEvents(sessionID string, expectedVersion int, events ...*models.Event) error {
+	record := &logRecord{
+		AggregateID: sessionID,
+		Events:      make([]logEvent, len(events)),
+	}
+	for i, event := range events {
+		data, err := event.EncodeData()
+		if err != nil {
+			return err
+		}
+
+		record.Events[i] = logEvent{
+			EventType:     event.EventType,
+			Data:          data,
+			CreatedAt:     event.CreatedAt,
+			SchemaVersion: event.SchemaVersion,
+		}
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if expectedVersion != AnyVersion && len(store.index[sessionID]) != expectedVersion {
+		return ErrVersionConflict
+	}
+
+	offset, err := store.writeRecord(record)
+	if err != nil {
+		return err
+	}
+
+	store.indexRecord(record, offset)
+	return nil
+}
+
+func (store *FileStore) snapshotPath(sessionID string) string {
+	return filepath.Join(store.dir, snapshotDirName, sessionID+".json")
+}
+
+// GetSnapshot returns latest snapshot of session
+func (store *FileStore) GetSnapshot(sessionID string) (*models.Snapshot, error) {
+	data, err := ioutil.ReadFile(store.snapshotPath(sessionID))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return models.DecodeSnapshot(data)
+}
+
+// SaveSnapshot writes snapshot into temporary file and renames it over
+// the previous one, so a crash never leaves half written snapshot
+func (store *FileStore) SaveSnapshot(snapshot *models.Snapshot) error {
+	data, err := snapshot.Encode()
+	if err != nil {
+		return err
+	}
+
+	path := store.snapshotPath(snapshot.SessionID)
+	tmpFile, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if _, err := tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpFile.Name(), path)
+}
+
+// ListSessions returns copies of all session summaries
+func (store *FileStore) ListSessions() ([]*models.SessionSummary, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	summaries := make([]*models.SessionSummary, 0, len(store.summaries))
+	for _, summary := range store.summaries {
+		summaryCopy := *summary
+		summaries = append(summaries, &summaryCopy)
+	}
+
+	return summaries, nil
+}
+
+// DeleteSession writes tombstone for session stream and removes its
+// snapshot. Space taken by deleted events stays in the log until it
+// is compacted, see Compact
+func (store *FileStore) DeleteSession(sessionID string, expectedVersion int) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if expectedVersion != AnyVersion && len(store.index[sessionID]) != expectedVersion {
+		return ErrVersionConflict
+	}
+
+	if _, ok := store.index[sessionID]; !ok {
+		return nil
+	}
+
+	record := &logRecord{
+		AggregateID: sessionID,
+		Deleted:     true,
+	}
+	offset, err := store.writeRecord(record)
+	if err != nil {
+		return err
+	}
+
+	store.indexRecord(record, offset)
+
+	if err := os.Remove(store.snapshotPath(sessionID)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Compact rewrites the log without records of deleted sessions and
+// tombstones. Live records are written into temporary file in their
+// order, which is then renamed over the log, so a crash leaves either
+// the old log or the compacted one
+func (store *FileStore) Compact() error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	live := map[int64]bool{}
+	for _, positions := range store.index {
+		for _, position := range positions {
+			live[position.offset] = true
+		}
+	}
+
+	path := filepath.Join(store.dir, logFileName)
+	tmpFile, err := ioutil.TempFile(store.dir, logFileName+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+
+	var offset int64
+	for offset < store.size {
+		record, size, err := store.readRecord(offset)
+		if err != nil {
+			tmpFile.Close()
+			return err
+		}
+
+		if live[offset] {
+			buf, err := encodeRecord(record)
+			if err == nil {
+				_, err = tmpFile.Write(buf)
+			}
+			if err != nil {
+				tmpFile.Close()
+				return err
+			}
+		}
+		offset += size
+	}
+
+	if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpFile.Name(), path); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	store.file.Close()
+	store.file = file
+	store.index = make(map[string][]eventPosition)
+	store.summaries = make(map[string]*models.SessionSummary)
+	return store.recover()
+}
+
+// Close closes underlying log file
+func (store *FileStore) Close() error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	return store.file.Close()
+}
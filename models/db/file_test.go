@@ -0,0 +1,247 @@
+package db
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/billyboar/battleships/models"
+)
+
+func TestFileStoreRecoversTornWrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "battleships")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal("failed to open store:", err)
+	}
+
+	session, err := models.NewSession()
+	if err != nil {
+		t.Fatal("failed to create session:", err)
+	}
+	if err := store.AppendEvent(session.ID, 0, models.CreateNewSessionEvent(session)); err != nil {
+		t.Fatal("failed to append event:", err)
+	}
+	shot := models.Cell{X: 1, Y: 2}
+	if err := store.AppendEvent(session.ID, 1, models.CreateShootEvent(session.ID, &shot, false, models.SideComputer)); err != nil {
+		t.Fatal("failed to append event:", err)
+	}
+	store.Close()
+
+	// simulate crash in the middle of writing the next record
+	logFile, err := os.OpenFile(filepath.Join(dir, logFileName), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logFile.Write([]byte{0, 0, 1, 0, 42, 42})
+	logFile.Close()
+
+	store, err = NewFileStore(dir)
+	if err != nil {
+		t.Fatal("failed to reopen store:", err)
+	}
+	defer store.Close()
+
+	events, err := store.GetEvents(session.ID)
+	if err != nil {
+		t.Fatal("failed to get events:", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events after recovery, got %d", len(events))
+	}
+	if _, err := models.BuildSessionEvents(events, session.ID, true); err != nil {
+		t.Fatal("failed to build session:", err)
+	}
+
+	// appends after recovery must land right after the last valid record
+	if err := store.AppendEvent(session.ID, 2, models.CreateShootEvent(session.ID, &shot, true, models.SidePlayer)); err != nil {
+		t.Fatal("failed to append event:", err)
+	}
+	store.Close()
+
+	store, err = NewFileStore(dir)
+	if err != nil {
+		t.Fatal("failed to reopen store:", err)
+	}
+	if events, _ := store.GetEvents(session.ID); len(events) != 3 {
+		t.Errorf("expected 3 events after reopening, got %d", len(events))
+	}
+}
+
+func TestFileStoreDropsTornBatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "battleships")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal("failed to open store:", err)
+	}
+
+	session, err := models.NewSession()
+	if err != nil {
+		t.Fatal("failed to create session:", err)
+	}
+	if err := store.AppendEvent(session.ID, 0, models.CreateNewSessionEvent(session)); err != nil {
+		t.Fatal("failed to append event:", err)
+	}
+	sizeBeforeBatch := store.size
+
+	shot := models.Cell{X: 4, Y: 4}
+	err = store.AppendEvents(session.ID, 1,
+		models.CreateShootEvent(session.ID, &shot, false, models.SideComputer),
+		models.CreateShootEvent(session.ID, &shot, true, models.SidePlayer),
+	)
+	if err != nil {
+		t.Fatal("failed to append events:", err)
+	}
+	store.Close()
+
+	// cut the batch record in half as if the process died mid-write
+	logPath := filepath.Join(dir, logFileName)
+	stat, err := os.Stat(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(logPath, sizeBeforeBatch+(stat.Size()-sizeBeforeBatch)/2); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err = NewFileStore(dir)
+	if err != nil {
+		t.Fatal("failed to reopen store:", err)
+	}
+	defer store.Close()
+
+	if events, _ := store.GetEvents(session.ID); len(events) != 1 {
+		t.Errorf("expected torn batch to be dropped entirely, got %d events", len(events))
+	}
+}
+
+func TestFileStoreRejectsCorruptRecordInTheMiddle(t *testing.T) {
+	dir, err := ioutil.TempDir("", "battleships")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal("failed to open store:", err)
+	}
+
+	first, _ := models.NewSession()
+	second, _ := models.NewSession()
+	if err := store.AppendEvent(first.ID, 0, models.CreateNewSessionEvent(first)); err != nil {
+		t.Fatal("failed to append event:", err)
+	}
+	secondOffset := store.size
+	if err := store.AppendEvent(second.ID, 0, models.CreateNewSessionEvent(second)); err != nil {
+		t.Fatal("failed to append event:", err)
+	}
+	shot := models.Cell{X: 1, Y: 2}
+	if err := store.AppendEvent(first.ID, 1, models.CreateShootEvent(first.ID, &shot, false, models.SideComputer)); err != nil {
+		t.Fatal("failed to append event:", err)
+	}
+	sizeBefore := store.size
+	store.Close()
+
+	// flip a byte inside payload of the second session record
+	logPath := filepath.Join(dir, logFileName)
+	logFile, err := os.OpenFile(logPath, os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, 1)
+	logFile.ReadAt(b, secondOffset+recordHeaderSize+10)
+	b[0] ^= 0xff
+	logFile.WriteAt(b, secondOffset+recordHeaderSize+10)
+	logFile.Close()
+
+	if store, err := NewFileStore(dir); err == nil {
+		store.Close()
+		t.Fatal("expected corrupt record in the middle of the log to fail opening")
+	}
+
+	stat, err := os.Stat(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.Size() != sizeBefore {
+		t.Errorf("expected log to keep %d bytes, got %d", sizeBefore, stat.Size())
+	}
+}
+
+func TestFileStoreCompactDropsDeletedSessions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "battleships")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal("failed to open store:", err)
+	}
+
+	deleted, _ := models.NewSession()
+	kept, _ := models.NewSession()
+	shot := models.Cell{X: 3, Y: 3}
+	if err := store.AppendEvent(deleted.ID, 0, models.CreateNewSessionEvent(deleted)); err != nil {
+		t.Fatal("failed to append event:", err)
+	}
+	if err := store.AppendEvent(kept.ID, 0, models.CreateNewSessionEvent(kept)); err != nil {
+		t.Fatal("failed to append event:", err)
+	}
+	if err := store.AppendEvent(deleted.ID, 1, models.CreateShootEvent(deleted.ID, &shot, false, models.SideComputer)); err != nil {
+		t.Fatal("failed to append event:", err)
+	}
+	if err := store.DeleteSession(deleted.ID, AnyVersion); err != nil {
+		t.Fatal("failed to delete session:", err)
+	}
+	sizeBefore := store.size
+
+	if err := store.Compact(); err != nil {
+		t.Fatal("failed to compact store:", err)
+	}
+	if store.size >= sizeBefore {
+		t.Errorf("expected compaction to shrink log of %d bytes, got %d", sizeBefore, store.size)
+	}
+
+	// appends after compaction must land at the end of compacted log
+	if err := store.AppendEvent(kept.ID, 1, models.CreateShootEvent(kept.ID, &shot, false, models.SideComputer)); err != nil {
+		t.Fatal("failed to append event:", err)
+	}
+	store.Close()
+
+	store, err = NewFileStore(dir)
+	if err != nil {
+		t.Fatal("failed to reopen store:", err)
+	}
+	defer store.Close()
+
+	if events, _ := store.GetEvents(deleted.ID); len(events) != 0 {
+		t.Errorf("expected deleted session to stay deleted, got %d events", len(events))
+	}
+	events, err := store.GetEvents(kept.ID)
+	if err != nil {
+		t.Fatal("failed to get events:", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events of kept session, got %d", len(events))
+	}
+	if _, err := models.BuildSessionEvents(events, kept.ID, true); err != nil {
+		t.Fatal("failed to build session:", err)
+	}
+	if summaries, _ := store.ListSessions(); len(summaries) != 1 {
+		t.Errorf("expected single session summary, got %d", len(summaries))
+	}
+}
@@ -0,0 +1,155 @@
+package db
+
+import (
+	"sync"
+
+	"github.com/billyboar/battleships/models"
+)
+
+// MemoryStore keeps session streams in memory. It is meant for tests
+// and local demos where redis is not available
+type MemoryStore struct {
+	mu        sync.RWMutex
+	streams   map[string][]*models.Event
+	snapshots map[string][]byte
+	summaries map[string]*models.SessionSummary
+}
+
+// NewMemoryStore creates empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		streams:   make(map[string][]*models.Event),
+		snapshots: make(map[string][]byte),
+		summaries: make(map[string]*models.SessionSummary),
+	}
+}
+
+// GetEvents returns copies of all events for a session stream
+func (store *MemoryStore) GetEvents(sessionID string) ([]*models.Event, error) {
+	return store.getEventsFrom(sessionID, 0)
+}
+
+// GetEventsSince returns copies of events following the snapshot version
+func (store *MemoryStore) GetEventsSince(snapshot *models.Snapshot) ([]*models.Event, error) {
+	return store.getEventsFrom(snapshot.SessionID, snapshot.Version)
+}
+
+func (store *MemoryStore) getEventsFrom(sessionID string, version int) ([]*models.Event, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	stream := store.streams[sessionID]
+	if version > len(stream) {
+		version = len(stream)
+	}
+
+	events := make([]*models.Event, 0, len(stream)-version)
+	for i, event := range stream[version:] {
+		eventCopy := *event
+		eventCopy.Sequence = version + i + 1
+		events = append(events, &eventCopy)
+	}
+
+	return events, nil
+}
+
+// GetSnapshot returns latest snapshot of session
+func (store *MemoryStore) GetSnapshot(sessionID string) (*models.Snapshot, error) {
+	store.mu.RLock()
+	data, ok := store.snapshots[sessionID]
+	store.mu.RUnlock()
+
+	if !ok {
+		return nil, nil
+	}
+	return models.DecodeSnapshot(data)
+}
+
+// SaveSnapshot stores encoded snapshot, so later changes to the
+// session do not leak into it
+func (store *MemoryStore) SaveSnapshot(snapshot *models.Snapshot) error {
+	data, err := snapshot.Encode()
+	if err != nil {
+		return err
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	store.snapshots[snapshot.SessionID] = data
+	return nil
+}
+
+// AppendEvent adds new event to stream
+func (store *MemoryStore) AppendEvent(sessionID string, expectedVersion int, event *models.Event) error {
+	return store.AppendEvents(sessionID, expectedVersion, event)
+}
+
+// AppendEvents adds events to stream. Event data is stored as JSON
+// string so replaying it behaves exactly like reading it from redis
+func (store *MemoryStore) AppendEvents(sessionID string, expectedVersion int, events ...*models.Event) error {
+	storedEvents := make([]*models.Event, len(events))
+	for i, event := range events {
+		data, err := event.EncodeData()
+		if err != nil {
+			return err
+		}
+
+		storedEvents[i] = &models.Event{
+			AggregateID:   sessionID,
+			Data:          data,
+			EventType:     event.EventType,
+			CreatedAt:     event.CreatedAt,
+			SchemaVersion: event.SchemaVersion,
+		}
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if expectedVersion != AnyVersion && len(store.streams[sessionID]) != expectedVersion {
+		return ErrVersionConflict
+	}
+
+	store.streams[sessionID] = append(store.streams[sessionID], storedEvents...)
+
+	summary, ok := store.summaries[sessionID]
+	if !ok {
+		summary = models.NewSessionSummary(sessionID)
+		store.summaries[sessionID] = summary
+	}
+	for _, event := range storedEvents {
+		summary.Update(event)
+	}
+
+	return nil
+}
+
+// ListSessions returns copies of all session summaries
+func (store *MemoryStore) ListSessions() ([]*models.SessionSummary, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	summaries := make([]*models.SessionSummary, 0, len(store.summaries))
+	for _, summary := range store.summaries {
+		summaryCopy := *summary
+		summaries = append(summaries, &summaryCopy)
+	}
+
+	return summaries, nil
+}
+
+// DeleteSession removes session stream, its snapshot and summary
+func (store *MemoryStore) DeleteSession(sessionID string, expectedVersion int) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if expectedVersion != AnyVersion && len(store.streams[sessionID]) != expectedVersion {
+		return ErrVersionConflict
+	}
+
+	delete(store.streams, sessionID)
+	delete(store.snapshots, sessionID)
+	delete(store.summaries, sessionID)
+	return nil
+}
@@ -0,0 +1,86 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/billyboar/battleships/models"
+)
+
+func TestMemoryStoreReplaysSession(t *testing.T) {
+	store := NewMemoryStore()
+
+	session, err := models.NewSession()
+	if err != nil {
+		t.Fatal("failed to create session:", err)
+	}
+
+	if err := store.AppendEvent(session.ID, 0, models.CreateNewSessionEvent(session)); err != nil {
+		t.Fatal("failed to append event:", err)
+	}
+	shot := session.Computer.Battleships[0].Cells[0]
+	if err := store.AppendEvent(session.ID, 1, models.CreateShootEvent(session.ID, &shot, false, models.SideComputer)); err != nil {
+		t.Fatal("failed to append event:", err)
+	}
+
+	events, err := store.GetEvents(session.ID)
+	if err != nil {
+		t.Fatal("failed to get events:", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(events))
+	}
+
+	replayed, err := models.BuildSessionEvents(events, session.ID, true)
+	if err != nil {
+		t.Fatal("failed to build session:", err)
+	}
+	if wounds := replayed.Computer.GetAllShipWounds(); len(wounds) != 1 || !wounds[0].Compare(&shot) {
+		t.Errorf("expected single wound at (%d, %d), got %v", shot.X, shot.Y, wounds)
+	}
+
+	summaries, err := store.ListSessions()
+	if err != nil {
+		t.Fatal("failed to list sessions:", err)
+	}
+	if len(summaries) != 1 || summaries[0].ID != session.ID {
+		t.Fatalf("expected only %s to be listed, got %v", session.ID, summaries)
+	}
+	if summaries[0].EventCount != 2 || summaries[0].Status != models.StatusInProgress {
+		t.Errorf("expected in progress session with 2 events, got %+v", summaries[0])
+	}
+
+	if err := store.DeleteSession(session.ID, 1); err != ErrVersionConflict {
+		t.Errorf("expected delete of stale version to conflict, got %v", err)
+	}
+	if err := store.DeleteSession(session.ID, 2); err != nil {
+		t.Fatal("failed to delete session:", err)
+	}
+	if events, _ := store.GetEvents(session.ID); len(events) != 0 {
+		t.Errorf("expected deleted session to have no events, got %d", len(events))
+	}
+}
+
+func TestMemoryStoreRejectsStaleVersion(t *testing.T) {
+	store := NewMemoryStore()
+
+	session, err := models.NewSession()
+	if err != nil {
+		t.Fatal("failed to create session:", err)
+	}
+	if err := store.AppendEvent(session.ID, 0, models.CreateNewSessionEvent(session)); err != nil {
+		t.Fatal("failed to append event:", err)
+	}
+
+	// two requests both loaded the session at version 1
+	shot := models.Cell{X: 3, Y: 3}
+	if err := store.AppendEvent(session.ID, 1, models.CreateShootEvent(session.ID, &shot, false, models.SideComputer)); err != nil {
+		t.Fatal("failed to append event:", err)
+	}
+	if err := store.AppendEvent(session.ID, 1, models.CreateShootEvent(session.ID, &shot, false, models.SideComputer)); err != ErrVersionConflict {
+		t.Errorf("expected version conflict, got %v", err)
+	}
+
+	if err := store.AppendEvent(session.ID, AnyVersion, models.CreateShootEvent(session.ID, &shot, true, models.SidePlayer)); err != nil {
+		t.Errorf("expected append without version check to succeed, got %v", err)
+	}
+}
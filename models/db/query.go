@@ -0,0 +1,126 @@
+package db
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/billyboar/battleships/models"
+)
+
+// Session list sort keys
+const (
+	SortByCreatedAt    = "created_at"
+	SortByLastActivity = "last_activity"
+)
+
+// ErrInvalidCursor is returned for cursors not issued by QuerySessions
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// SessionQuery filters, sorts and pages session summaries
+type SessionQuery struct {
+	PlayerID   string
+	Status     models.SessionStatus
+	SortBy     string // SortByCreatedAt or SortByLastActivity
+	Descending bool
+	Limit      int
+	Cursor     string // NextCursor of the previous page
+}
+
+// SessionPage is a single page of session summaries
+type SessionPage struct {
+	Sessions   []*models.SessionSummary `json:"sessions"`
+	NextCursor string                   `json:"next_cursor,omitempty"`
+}
+
+// sortKey returns the time summaries are sorted by
+func (q *SessionQuery) sortKey(summary *models.SessionSummary) time.Time {
+	if q.SortBy == SortByLastActivity {
+		return summary.LastActivity
+	}
+	return summary.CreatedAt
+}
+
+// less orders summaries by sort key and then by ID, so the order is
+// total and cursors stay stable between requests
+func (q *SessionQuery) less(keyA time.Time, idA string, keyB time.Time, idB string) bool {
+	if !keyA.Equal(keyB) {
+		if q.Descending {
+			return keyA.After(keyB)
+		}
+		return keyA.Before(keyB)
+	}
+	if q.Descending {
+		return idA > idB
+	}
+	return idA < idB
+}
+
+// QuerySessions applies query to summaries. Cursor encodes sort key and
+// ID of the last returned summary, so sessions created between requests
+// do not shift pages
+func QuerySessions(summaries []*models.SessionSummary, query SessionQuery) (*SessionPage, error) {
+	var cursorKey time.Time
+	var cursorID string
+	if query.Cursor != "" {
+		var err error
+		if cursorKey, cursorID, err = decodeCursor(query.Cursor); err != nil {
+			return nil, err
+		}
+	}
+
+	filtered := []*models.SessionSummary{}
+	for _, summary := range summaries {
+		if query.PlayerID != "" && summary.PlayerID != query.PlayerID {
+			continue
+		}
+		if query.Status != "" && summary.Status != query.Status {
+			continue
+		}
+		if query.Cursor != "" && !query.less(cursorKey, cursorID, query.sortKey(summary), summary.ID) {
+			continue
+		}
+		filtered = append(filtered, summary)
+	}
+
+	sort.Slice(filtered, func(i, j int) bool {
+		return query.less(query.sortKey(filtered[i]), filtered[i].ID, query.sortKey(filtered[j]), filtered[j].ID)
+	})
+
+	page := &SessionPage{
+		Sessions: filtered,
+	}
+	if query.Limit > 0 && len(filtered) > query.Limit {
+		page.Sessions = filtered[:query.Limit]
+		last := page.Sessions[query.Limit-1]
+		page.NextCursor = encodeCursor(query.sortKey(last), last.ID)
+	}
+
+	return page, nil
+}
+
+func encodeCursor(key time.Time, id string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d:%s", key.UnixNano(), id)))
+}
+
+func decodeCursor(cursor string) (time.Time, string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+
+	parts := strings.SplitN(string(raw), ":", 2)
+	if len(parts) != 2 {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+	nanos, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+
+	return time.Unix(0, nanos), parts[1], nil
+}
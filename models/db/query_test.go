@@ -0,0 +1,56 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/billyboar/battleships/models"
+)
+
+func TestQuerySessionsPaging(t *testing.T) {
+	start := time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC)
+	summaries := []*models.SessionSummary{}
+	for i, id := range []string{"a", "b", "c", "d", "e"} {
+		playerID := "alice"
+		if i%2 == 1 {
+			playerID = "bob"
+		}
+		summaries = append(summaries, &models.SessionSummary{
+			ID:        id,
+			PlayerID:  playerID,
+			Status:    models.StatusCreated,
+			CreatedAt: start.Add(time.Duration(i) * time.Minute),
+		})
+	}
+
+	query := SessionQuery{
+		PlayerID:   "alice",
+		SortBy:     SortByCreatedAt,
+		Descending: true,
+		Limit:      2,
+	}
+
+	var ids []string
+	for {
+		page, err := QuerySessions(summaries, query)
+		if err != nil {
+			t.Fatal("failed to query sessions:", err)
+		}
+		for _, summary := range page.Sessions {
+			ids = append(ids, summary.ID)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+
+	if len(ids) != 3 || ids[0] != "e" || ids[1] != "c" || ids[2] != "a" {
+		t.Errorf("expected alice sessions e, c, a, got %v", ids)
+	}
+
+	query.Cursor = "not a cursor"
+	if _, err := QuerySessions(summaries, query); err != ErrInvalidCursor {
+		t.Errorf("expected invalid cursor error, got %v", err)
+	}
+}
@@ -1,25 +1,261 @@
 package db
 
 import (
+	"encoding/json"
+
 	"github.com/billyboar/battleships/models"
+	"github.com/go-redis/redis"
 )
 
+// summariesKey is redis hash holding JSON encoded summary
+// of every session keyed by session ID
+const summariesKey = "session_summaries"
+
+// snapshotKey returns key of the string holding session snapshot
+func snapshotKey(sessionID string) string {
+	return "snapshot:" + sessionID
+}
+
 // GetEvents returns all events for a session stream
 func (store *Store) GetEvents(sessionID string) ([]*models.Event, error) {
-	events, err := store.connection.XRange(sessionID, "-", "+").Result()
+	messages, err := store.connection.XRange(sessionID, "-", "+").Result()
+	if err != nil {
+		return nil, err
+	}
+
+	return deserializeMessages(sessionID, 0, messages), nil
+}
+
+// GetEventsSince returns events of a stream following snapshot stream ID
+func (store *Store) GetEventsSince(snapshot *models.Snapshot) ([]*models.Event, error) {
+	messages, err := store.connection.XRange(snapshot.SessionID, snapshot.StreamID, "+").Result()
+	if err != nil {
+		return nil, err
+	}
+
+	// XRANGE start is inclusive, skip the event snapshot already covers
+	if len(messages) > 0 && messages[0].ID == snapshot.StreamID {
+		messages = messages[1:]
+	}
+	return deserializeMessages(snapshot.SessionID, snapshot.Version, messages), nil
+}
+
+// deserializeMessages converts stream entries following first
+// version entries of the stream into events
+func deserializeMessages(sessionID string, version int, messages []redis.XMessage) []*models.Event {
+	events := make([]*models.Event, len(messages))
+	for i, message := range messages {
+		events[i] = models.DeserializeRedisStream(sessionID, version+i+1, message)
+	}
+
+	return events
+}
+
+// GetSnapshot returns latest snapshot of session
+func (store *Store) GetSnapshot(sessionID string) (*models.Snapshot, error) {
+	data, err := store.connection.Get(snapshotKey(sessionID)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	deserializedEvents := make([]*models.Event, len(events))
-	for i, event := range events {
-		deserializedEvents[i] = models.DeserializeRedisStream(event)
+	return models.DecodeSnapshot(data)
+}
+
+// SaveSnapshot stores snapshot of session
+func (store *Store) SaveSnapshot(snapshot *models.Snapshot) error {
+	data, err := snapshot.Encode()
+	if err != nil {
+		return err
 	}
 
-	return deserializedEvents, nil
+	return store.connection.Set(snapshotKey(snapshot.SessionID), data, 0).Err()
 }
 
 // AppendEvent adds new event to stream
-func (store *Store) AppendEvent(sessionID string, event *models.Event) error {
-	return store.connection.XAdd(event.SerializeRedisStream()).Err()
+func (store *Store) AppendEvent(sessionID string, expectedVersion int, event *models.Event) error {
+	return store.AppendEvents(sessionID, expectedVersion, event)
+}
+
+// AppendEvents adds events to stream within single MULTI/EXEC. Stream key
+// is watched while its length is checked, so a concurrent append aborts
+// the transaction
+func (store *Store) AppendEvents(sessionID string, expectedVersion int, events ...*models.Event) error {
+	err := store.connection.Watch(func(tx *redis.Tx) error {
+		if expectedVersion != AnyVersion {
+			length, err := tx.XLen(sessionID).Result()
+			if err != nil {
+				return err
+			}
+			if int(length) != expectedVersion {
+				return ErrVersionConflict
+			}
+		}
+
+		// summary is changed only together with the watched stream,
+		// so reading it here is safe
+		summary, err := store.getSummary(tx, sessionID)
+		if err != nil {
+			return err
+		}
+		for _, event := range events {
+			summary.Update(event)
+		}
+		summaryJSON, err := json.Marshal(summary)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Pipelined(func(pipe redis.Pipeliner) error {
+			for _, event := range events {
+				args := event.SerializeRedisStream()
+				args.Stream = sessionID
+				args.Values[models.AggregateIDKey] = sessionID
+				pipe.XAdd(args)
+			}
+			pipe.HSet(summariesKey, sessionID, summaryJSON)
+			return nil
+		})
+		return err
+	}, sessionID)
+
+	if err == redis.TxFailedErr {
+		return ErrVersionConflict
+	}
+	return err
+}
+
+// getSummary returns summary of session, streams written before
+// summaries were kept get theirs rebuilt from their events
+func (store *Store) getSummary(tx *redis.Tx, sessionID string) (*models.SessionSummary, error) {
+	data, err := tx.HGet(summariesKey, sessionID).Bytes()
+	if err == redis.Nil {
+		messages, err := tx.XRange(sessionID, "-", "+").Result()
+		if err != nil {
+			return nil, err
+		}
+		return buildSummary(sessionID, messages), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var summary models.SessionSummary
+	if err := json.Unmarshal(data, &summary); err != nil {
+		return nil, err
+	}
+	return &summary, nil
+}
+
+// buildSummary replays stream entries of session into its summary
+func buildSummary(sessionID string, messages []redis.XMessage) *models.SessionSummary {
+	summary := models.NewSessionSummary(sessionID)
+	for _, event := range deserializeMessages(sessionID, 0, messages) {
+		summary.Update(event)
+	}
+	return summary
+}
+
+// RebuildSummaries adds summaries of session streams missing from the
+// index, those were written before summaries were kept. It returns the
+// number of summaries added
+func (store *Store) RebuildSummaries() (int, error) {
+	var cursor uint64
+	added := 0
+	for {
+		keys, next, err := store.connection.Scan(cursor, "", 100).Result()
+		if err != nil {
+			return added, err
+		}
+
+		for _, key := range keys {
+			ok, err := store.rebuildSummary(key)
+			if err != nil {
+				return added, err
+			}
+			if ok {
+				added++
+			}
+		}
+
+		if next == 0 {
+			return added, nil
+		}
+		cursor = next
+	}
+}
+
+// rebuildSummary adds summary of the key when it is a session stream
+// without one and reports if it did
+func (store *Store) rebuildSummary(key string) (bool, error) {
+	keyType, err := store.connection.Type(key).Result()
+	if err != nil || keyType != "stream" {
+		return false, err
+	}
+	exists, err := store.connection.HExists(summariesKey, key).Result()
+	if err != nil || exists {
+		return false, err
+	}
+
+	messages, err := store.connection.XRange(key, "-", "+").Result()
+	if err != nil {
+		return false, err
+	}
+	summaryJSON, err := json.Marshal(buildSummary(key, messages))
+	if err != nil {
+		return false, err
+	}
+
+	// concurrent append may have written newer summary meanwhile
+	return store.connection.HSetNX(summariesKey, key, summaryJSON).Result()
+}
+
+// ListSessions returns summaries of all sessions
+func (store *Store) ListSessions() ([]*models.SessionSummary, error) {
+	values, err := store.connection.HGetAll(summariesKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := make([]*models.SessionSummary, 0, len(values))
+	for _, value := range values {
+		var summary models.SessionSummary
+		if err := json.Unmarshal([]byte(value), &summary); err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, &summary)
+	}
+
+	return summaries, nil
+}
+
+// DeleteSession removes session stream, its snapshot and summary.
+// Stream key is watched while its length is checked, the same way
+// AppendEvents does
+func (store *Store) DeleteSession(sessionID string, expectedVersion int) error {
+	err := store.connection.Watch(func(tx *redis.Tx) error {
+		if expectedVersion != AnyVersion {
+			length, err := tx.XLen(sessionID).Result()
+			if err != nil {
+				return err
+			}
+			if int(length) != expectedVersion {
+				return ErrVersionConflict
+			}
+		}
+
+		_, err := tx.Pipelined(func(pipe redis.Pipeliner) error {
+			pipe.Del(sessionID, snapshotKey(sessionID))
+			pipe.HDel(summariesKey, sessionID)
+			return nil
+		})
+		return err
+	}, sessionID)
+
+	if err == redis.TxFailedErr {
+		return ErrVersionConflict
+	}
+	return err
 }
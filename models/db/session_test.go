@@ -0,0 +1,41 @@
+package db
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/billyboar/battleships/models"
+	"github.com/go-redis/redis"
+)
+
+// streamMessage converts event into entry as read back from redis
+// stream, which holds every value as string
+func streamMessage(id string, event *models.Event) redis.XMessage {
+	values := map[string]interface{}{}
+	for key, value := range event.SerializeRedisStream().Values {
+		values[key] = fmt.Sprint(value)
+	}
+	return redis.XMessage{ID: id, Values: values}
+}
+
+func TestBuildSummaryOfExistingStream(t *testing.T) {
+	session, err := models.NewSession()
+	if err != nil {
+		t.Fatal("failed to create session:", err)
+	}
+	session.PlayerID = "alice"
+
+	shot := models.Cell{X: 1, Y: 2}
+	messages := []redis.XMessage{
+		streamMessage("1-0", models.CreateNewSessionEvent(session)),
+		streamMessage("2-0", models.CreateShootEvent(session.ID, &shot, false, models.SideComputer)),
+	}
+
+	summary := buildSummary(session.ID, messages)
+	if summary.Status != models.StatusInProgress || summary.PlayerID != "alice" {
+		t.Errorf("expected in progress session of alice, got %s of %q", summary.Status, summary.PlayerID)
+	}
+	if summary.CreatedAt.IsZero() || summary.EventCount != 2 {
+		t.Errorf("expected creation time and 2 events, got %v and %d", summary.CreatedAt, summary.EventCount)
+	}
+}
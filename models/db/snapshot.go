@@ -0,0 +1,51 @@
+package db
+
+import (
+	"log"
+
+	"github.com/billyboar/battleships/config"
+	"github.com/billyboar/battleships/models"
+)
+
+// LoadSession rebuilds session from its latest snapshot and events
+// appended after it. When snapshots are enabled and at least
+// SnapshotFrequency events were replayed, new snapshot is saved
+func LoadSession(store EventStore, sessionID string, conf *config.Config) (*models.Session, error) {
+	snapshot, err := store.GetSnapshot(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	var session *models.Session
+	var events []*models.Event
+	if snapshot != nil && snapshot.IsCurrent() {
+		if events, err = store.GetEventsSince(snapshot); err != nil {
+			return nil, err
+		}
+
+		session, err = models.BuildSessionFromSnapshot(snapshot, events, conf.StrictReplay)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		if events, err = store.GetEvents(sessionID); err != nil {
+			return nil, err
+		}
+
+		session, err = models.BuildSessionEvents(events, sessionID, conf.StrictReplay)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// damaged sessions are never snapshotted, so skipped events
+	// keep being reported
+	if conf.SnapshotFrequency > 0 && len(events) >= conf.SnapshotFrequency && len(session.ReplayErrors) == 0 {
+		// failing to save snapshot only makes next load slower
+		if err := store.SaveSnapshot(models.NewSnapshot(session)); err != nil {
+			log.Println("cannot save snapshot of session", sessionID, err)
+		}
+	}
+
+	return session, nil
+}
@@ -0,0 +1,66 @@
+package db
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/billyboar/battleships/config"
+	"github.com/billyboar/battleships/models"
+)
+
+func TestLoadSessionFromSnapshot(t *testing.T) {
+	store := NewMemoryStore()
+
+	session, err := models.NewSession()
+	if err != nil {
+		t.Fatal("failed to create session:", err)
+	}
+	if err := store.AppendEvent(session.ID, 0, models.CreateNewSessionEvent(session)); err != nil {
+		t.Fatal("failed to append event:", err)
+	}
+
+	for i := 0; i < 6; i++ {
+		shot := models.Cell{X: i, Y: i}
+		nextTurn := models.SideComputer
+		if i%2 == 1 {
+			nextTurn = models.SidePlayer
+		}
+		if err := store.AppendEvent(session.ID, AnyVersion, models.CreateShootEvent(session.ID, &shot, i%2 == 1, nextTurn)); err != nil {
+			t.Fatal("failed to append event:", err)
+		}
+
+		if i == 2 {
+			if _, err := LoadSession(store, session.ID, &config.Config{SnapshotFrequency: 2, StrictReplay: true}); err != nil {
+				t.Fatal("failed to load session:", err)
+			}
+		}
+	}
+
+	snapshot, err := store.GetSnapshot(session.ID)
+	if err != nil {
+		t.Fatal("failed to get snapshot:", err)
+	}
+	if snapshot == nil || snapshot.Version != 4 {
+		t.Fatalf("expected snapshot covering 4 events, got %+v", snapshot)
+	}
+
+	fromSnapshot, err := LoadSession(store, session.ID, &config.Config{StrictReplay: true})
+	if err != nil {
+		t.Fatal("failed to load session:", err)
+	}
+
+	events, _ := store.GetEvents(session.ID)
+	fullReplay, err := models.BuildSessionEvents(events, session.ID, true)
+	if err != nil {
+		t.Fatal("failed to build session:", err)
+	}
+
+	if fromSnapshot.Version != fullReplay.Version {
+		t.Errorf("expected version %d, got %d", fullReplay.Version, fromSnapshot.Version)
+	}
+	expected, _ := json.Marshal(fullReplay)
+	actual, _ := json.Marshal(fromSnapshot)
+	if string(expected) != string(actual) {
+		t.Errorf("session built from snapshot differs from full replay:\n%s\n%s", expected, actual)
+	}
+}
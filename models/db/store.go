@@ -1,20 +1,65 @@
 package db
 
 import (
+	"errors"
+
+	"github.com/billyboar/battleships/models"
 	"github.com/go-redis/redis"
 )
 
+// ErrVersionConflict is returned when session stream has moved past
+// the version an append was based on
+var ErrVersionConflict = errors.New("session stream version conflict")
+
+// AnyVersion skips stream version check on append
+const AnyVersion = -1
+
+// EventStore persists session event streams. Every backend must return
+// events in the order they were appended and keep event data in its
+// serialized JSON form, the same way redis streams do
+type EventStore interface {
+	// GetEvents returns all events for a session stream
+	GetEvents(sessionID string) ([]*models.Event, error)
+	// AppendEvent adds new event to the end of session stream. It fails
+	// with ErrVersionConflict unless stream holds exactly expectedVersion
+	// events, see AnyVersion
+	AppendEvent(sessionID string, expectedVersion int, event *models.Event) error
+	// AppendEvents adds all given events to session stream atomically,
+	// either every event is appended or none of them
+	AppendEvents(sessionID string, expectedVersion int, events ...*models.Event) error
+	// GetEventsSince returns events appended after snapshot was taken
+	GetEventsSince(snapshot *models.Snapshot) ([]*models.Event, error)
+	// GetSnapshot returns latest snapshot of session, nil if there is none
+	GetSnapshot(sessionID string) (*models.Snapshot, error)
+	// SaveSnapshot stores snapshot replacing previous one of the session
+	SaveSnapshot(snapshot *models.Snapshot) error
+	// ListSessions returns index entries of all stored sessions,
+	// see QuerySessions for filtering them
+	ListSessions() ([]*models.SessionSummary, error)
+	// DeleteSession removes session stream with all of its events
+	// and snapshot. It fails with ErrVersionConflict unless stream holds
+	// exactly expectedVersion events, see AnyVersion
+	DeleteSession(sessionID string, expectedVersion int) error
+}
+
+// Store is redis streams backed EventStore
 type Store struct {
 	connection *redis.Client
 }
 
+// NewStore connects to redis and creates new store. Sessions stored
+// before summaries were kept are added to the index
 func NewStore() (*Store, error) {
 	client, err := ConnectDB()
 	if err != nil {
 		return nil, err
 	}
 
-	return &Store{
+	store := &Store{
 		connection: client,
-	}, nil
+	}
+	if _, err := store.RebuildSummaries(); err != nil {
+		return nil, err
+	}
+	return store, nil
 }
@@ -0,0 +1,157 @@
+package models
+
+import (
+	"fmt"
+
+	"github.com/billyboar/battleships/helpers"
+)
+
+// hitWeight multiplies weight of ship position for every open hit it
+// covers, so hit ships are finished before new ones are looked for
+const hitWeight = 10
+
+// densityShot picks cell which most of possible positions of ships
+// afloat go through. Positions are counted from what shooter knows,
+// those are misses, hits and ships announced as sunk. Nil is returned
+// when no position fits
+func (b *Board) densityShot(planned CellMap) *Cell {
+	known := b.knownCells(planned)
+	sonarCells := b.applySonar(known)
+	openHits := b.openHits()
+
+	// positions covering open hits are kept apart, they decide the shot
+	// as long as any of them is left
+	hunt := make([]int, b.Width*b.Height)
+	target := make([]int, b.Width*b.Height)
+	for _, class := range b.remainingClasses() {
+		for _, ship := range orientedShips(class.Length, class.Shape) {
+			for x := 0; x < b.Width; x++ {
+				for y := 0; y < b.Height; y++ {
+					cells := ship.BodyCells(Cell{X: x, Y: y})
+					weight, fits := b.positionWeight(cells, known, openHits, planned)
+					if !fits {
+						continue
+					}
+
+					density := hunt
+					if weight > 0 {
+						density = target
+					} else {
+						weight = 1
+					}
+					for _, cell := range cells {
+						if !known[cell.X][cell.Y] {
+							density[cell.Y*b.Width+cell.X] += weight * class.Count
+						}
+					}
+				}
+			}
+		}
+	}
+
+	if shot := b.densestCell(target, nil); shot != nil {
+		return shot
+	}
+	// areas where sonar found ships are searched first
+	if shot := b.densestCell(hunt, sonarCells); shot != nil {
+		return shot
+	}
+	return b.densestCell(hunt, nil)
+}
+
+// openHits returns hits of ships which may still be afloat, hits of
+// ships announced as sunk are accounted for
+func (b *Board) openHits() CellMap {
+	sunkCells := CellMap{}
+	if !b.HiddenSinks {
+		for _, ship := range b.GetDeadShips() {
+			for _, cell := range ship.Cells {
+				sunkCells.add(cell)
+			}
+		}
+	}
+
+	openHits := CellMap{}
+	for _, hit := range b.Hits() {
+		if !sunkCells[hit.X][hit.Y] {
+			openHits.add(hit)
+		}
+	}
+	return openHits
+}
+
+// remainingClasses returns classes of ships which may still be afloat,
+// as far as shooter knows. Ships are grouped by length and shape too,
+// ships of sessions recorded before rule sets have no names
+func (b *Board) remainingClasses() []ShipClass {
+	var classes []ShipClass
+	index := map[string]int{}
+	for _, ship := range b.Battleships {
+		if ship.IsDead && !b.HiddenSinks {
+			continue
+		}
+
+		key := fmt.Sprint(ship.Name, ship.Length, ship.Shape)
+		i, ok := index[key]
+		if !ok {
+			i = len(classes)
+			index[key] = i
+			classes = append(classes, ShipClass{Name: ship.Name, Length: ship.Length, Shape: ship.Shape})
+		}
+		classes[i].Count++
+	}
+	return classes
+}
+
+// positionWeight reports if ship may lie on the cells, that is they are
+// unknown or open hits. Weight grows with open hits covered, it is 0
+// when none is
+func (b *Board) positionWeight(cells []Cell, known, openHits, planned CellMap) (weight int, fits bool) {
+	for _, cell := range cells {
+		if !b.Contains(cell) {
+			return 0, false
+		}
+		if openHits[cell.X][cell.Y] {
+			if weight == 0 {
+				weight = 1
+			}
+			weight *= hitWeight
+			continue
+		}
+		// planned shots are not fired yet, ship may still be there
+		if known[cell.X][cell.Y] && !planned[cell.X][cell.Y] {
+			return 0, false
+		}
+	}
+	return weight, true
+}
+
+// densestCell returns random one of cells with the highest density,
+// limited to area unless it is nil. Nil is returned when every density
+// is 0
+func (b *Board) densestCell(density []int, area CellMap) *Cell {
+	var cells []Cell
+	best := 0
+	for x := 0; x < b.Width; x++ {
+		for y := 0; y < b.Height; y++ {
+			if area != nil && !area[x][y] {
+				continue
+			}
+
+			value := density[y*b.Width+x]
+			if value == 0 || value < best {
+				continue
+			}
+			if value > best {
+				best = value
+				cells = cells[:0]
+			}
+			cells = append(cells, Cell{X: x, Y: y})
+		}
+	}
+
+	if len(cells) == 0 {
+		return nil
+	}
+	return &cells[helpers.GenerateRandomInt(len(cells))]
+}
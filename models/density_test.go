@@ -0,0 +1,113 @@
+package models
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// playOut shoots at the board until its fleet is sunk and returns
+// number of shots fired
+func playOut(t *testing.T, board *Board, shoot func() *Cell) int {
+	shots := 0
+	for !board.IsFleetDestroyed() {
+		shot := shoot()
+		if shot == nil {
+			t.Fatal("no shot left before fleet was destroyed")
+		}
+		if _, shipID := board.RegisterShot(*shot); shipID != "" {
+			board.MarkShipIfDead(shipID)
+		}
+		shots++
+	}
+	return shots
+}
+
+func TestDensityShotWinsFaster(t *testing.T) {
+	rules, _ := RuleSetByName("classic")
+	densityShots, huntShots := 0, 0
+	for i := 0; i < 30; i++ {
+		board, err := GenerateBoard(false, rules)
+		if err != nil {
+			t.Fatal("failed to create a board:", err)
+		}
+		densityShots += playOut(t, board, board.CalculateShot)
+
+		board, _ = GenerateBoard(false, rules)
+		huntShots += playOut(t, board, func() *Cell { return board.huntTargetShot(CellMap{}) })
+	}
+
+	if densityShots >= huntShots {
+		t.Errorf("expected density to sink fleets faster, took %d shots against %d", densityShots, huntShots)
+	}
+}
+
+func TestDensityShotTargetsWoundedShips(t *testing.T) {
+	board := NewBoard(false)
+	var ships []*BattleShip
+	for _, head := range []Cell{{X: 1, Y: 1}, {X: 5, Y: 6}} {
+		ship, _ := NewBattleShip("cruiser", 3)
+		ship.IsVertical = false
+		ship.BuildBody(head)
+		ships = append(ships, ship)
+	}
+	board.Battleships = ships
+
+	// first cruiser is hit twice, second one once
+	for _, cell := range []Cell{{X: 1, Y: 1}, {X: 2, Y: 1}, {X: 6, Y: 6}} {
+		board.RegisterShot(cell)
+	}
+	for i := 0; i < 20; i++ {
+		shot := board.CalculateShot()
+		if shot == nil || shot.Y != 1 || (shot.X != 0 && shot.X != 3) {
+			t.Fatalf("expected line of hits to be followed, got %v", shot)
+		}
+	}
+
+	board.RegisterShot(Cell{X: 3, Y: 1})
+	board.MarkShipIfDead(ships[0].ID)
+	shot := board.CalculateShot()
+	if shot == nil || abs(shot.X-6)+abs(shot.Y-6) != 1 {
+		t.Errorf("expected the other wounded ship to be targeted, got %v", shot)
+	}
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func TestRemainingClassesOfLegacyStreams(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "streams", "v1_*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range paths {
+		fixture, events := loadStreamFixture(t, path)
+		session, err := BuildSessionEvents(events, fixture.SessionID, true)
+		if err != nil {
+			t.Fatalf("%s: cannot replay stream: %v", path, err)
+		}
+
+		for _, board := range []*Board{session.Player, session.Computer} {
+			// ships of legacy streams have no names, classes must
+			// still keep their lengths apart
+			expected := map[int]int{}
+			for _, ship := range board.Battleships {
+				if !ship.IsDead {
+					expected[ship.Length]++
+				}
+			}
+			classes := map[int]int{}
+			for _, class := range board.remainingClasses() {
+				classes[class.Length] += class.Count
+			}
+			if !reflect.DeepEqual(classes, expected) {
+				t.Errorf("%s: expected ships afloat by length %v, got %v", path, expected, classes)
+			}
+		}
+	}
+}
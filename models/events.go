@@ -3,6 +3,9 @@ package models
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-redis/redis"
@@ -14,41 +17,135 @@ type Event struct {
 	Data        interface{}
 	EventType   string
 	CreatedAt   time.Time
+	StreamID    string // position of event in the store, redis stream entry ID
+	Sequence    int    // 1-based number of event in its stream
+	// SchemaVersion is the version of Data shape, see Upcast
+	SchemaVersion int
 }
 
 const (
-	EventTypeKey = "event_type"
-	DataKey      = "data"
+	AggregateIDKey = "aggregate_id"
+	EventTypeKey   = "event_type"
+	DataKey        = "data"
+	CreatedAtKey   = "created_at"
+	SchemaKey      = "schema_version"
 )
 
 // SerializeRedisStream serializes to datatype that redis stream supports
 func (e *Event) SerializeRedisStream() *redis.XAddArgs {
-	dataJSON, _ := json.Marshal(e.Data)
+	data, _ := e.EncodeData()
 
 	return &redis.XAddArgs{
 		Stream: e.AggregateID,
 		Values: map[string]interface{}{
-			"event_type": e.EventType,
-			"data":       dataJSON,
-			"created_at": e.CreatedAt,
+			AggregateIDKey: e.AggregateID,
+			EventTypeKey:   e.EventType,
+			DataKey:        data,
+			CreatedAtKey:   e.CreatedAt.Format(time.RFC3339Nano),
+			SchemaKey:      e.SchemaVersion,
 		},
 	}
 }
 
-func DeserializeRedisStream(message redis.XMessage) *Event {
-	return &Event{
-		Data:      message.Values[DataKey],
-		EventType: message.Values[EventTypeKey].(string),
+// EncodeData returns event data as JSON string. Data that was read back
+// from a store is already encoded and is returned as it is
+func (e *Event) EncodeData() (string, error) {
+	if data, ok := e.Data.(string); ok {
+		return data, nil
+	}
+
+	dataJSON, err := json.Marshal(e.Data)
+	if err != nil {
+		return "", err
+	}
+	return string(dataJSON), nil
+}
+
+// DeserializeRedisStream restores event from redis stream entry, sequence
+// is the position of the entry in its stream
+func DeserializeRedisStream(stream string, sequence int, message redis.XMessage) *Event {
+	// missing fields are left empty and reported during replay
+	eventType, _ := message.Values[EventTypeKey].(string)
+	event := &Event{
+		AggregateID: stream,
+		Data:        message.Values[DataKey],
+		EventType:   eventType,
+		CreatedAt:   parseRedisCreatedAt(message),
+		StreamID:    message.ID,
+		Sequence:    sequence,
+	}
+
+	if aggregateID, ok := message.Values[AggregateIDKey].(string); ok && aggregateID != "" {
+		event.AggregateID = aggregateID
+	}
+	if schemaVersion, ok := message.Values[SchemaKey].(string); ok {
+		event.SchemaVersion, _ = strconv.Atoi(schemaVersion)
+	}
+
+	return event
+}
+
+// parseRedisCreatedAt reads event creation time. Entries written before
+// RFC3339 encoding hold binary marshaled time, and if neither can be
+// read the time is taken from milliseconds part of the entry ID
+func parseRedisCreatedAt(message redis.XMessage) time.Time {
+	value, _ := message.Values[CreatedAtKey].(string)
+
+	if createdAt, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return createdAt
+	}
+
+	var createdAt time.Time
+	if err := createdAt.UnmarshalBinary([]byte(value)); err == nil {
+		return createdAt
+	}
+
+	idParts := strings.SplitN(message.ID, "-", 2)
+	if millis, err := strconv.ParseInt(idParts[0], 10, 64); err == nil {
+		return time.Unix(0, millis*int64(time.Millisecond))
+	}
+
+	return time.Time{}
+}
+
+// ErrSessionNotFound is returned when session stream has no events
+var ErrSessionNotFound = errors.New("session not found")
+
+// ReplayError describes event which could not be applied to session
+type ReplayError struct {
+	Index     int    `json:"index"`    // index of event in replayed slice
+	Sequence  int    `json:"sequence"` // position of event in its stream
+	EventType string `json:"event_type"`
+	Reason    string `json:"reason"`
+	Err       error  `json:"-"`
+}
+
+func (e *ReplayError) Error() string {
+	return fmt.Sprintf("cannot apply %q event #%d (index %d): %s", e.EventType, e.Sequence, e.Index, e.Reason)
+}
+
+func newReplayError(index int, event *Event, err error) *ReplayError {
+	return &ReplayError{
+		Index:     index,
+		Sequence:  event.Sequence,
+		EventType: event.EventType,
+		Reason:    err.Error(),
+		Err:       err,
 	}
 }
 
-func BuildSessionEvents(events []*Event, sessionID string) (*Session, error) {
+// BuildSessionEvents replays whole session stream. In strict mode the
+// first event which cannot be applied fails the replay, otherwise such
+// events are skipped and collected in session ReplayErrors. Session
+// cannot be built without its new_session event, so that one fails
+// the replay in both modes
+func BuildSessionEvents(events []*Event, sessionID string, strict bool) (*Session, error) {
 	if len(events) == 0 {
-		return nil, errors.New("received no events")
+		return nil, ErrSessionNotFound
 	}
 
 	if events[0].EventType != NewSessionEventType {
-		return nil, errors.New("initial event is not valid")
+		return nil, newReplayError(0, events[0], errors.New("initial event is not valid"))
 	}
 
 	session := &Session{
@@ -57,113 +154,226 @@ func BuildSessionEvents(events []*Event, sessionID string) (*Session, error) {
 		Player:   NewBoard(false),
 	}
 
-	for _, event := range events {
-		session.Apply(event)
+	if err := session.ApplyEvents(events, strict); err != nil {
+		return nil, err
 	}
 
 	return session, nil
 }
 
+// ApplyEvents applies events in order and moves session version
+// past each of them, see BuildSessionEvents for strict mode
+func (s *Session) ApplyEvents(events []*Event, strict bool) error {
+	for i, event := range events {
+		if err := s.Apply(event); err != nil {
+			replayErr := newReplayError(i, event, err)
+			if strict || event.EventType == NewSessionEventType {
+				return replayErr
+			}
+			s.ReplayErrors = append(s.ReplayErrors, replayErr)
+		}
+
+		s.Version++
+		s.StreamID = event.StreamID
+	}
+
+	return nil
+}
+
 func (s *Session) Apply(event *Event) error {
+	if err := Upcast(event); err != nil {
+		return err
+	}
+
 	switch event.EventType {
 	case NewSessionEventType:
-		if err := s.ApplyCreateSessionEvent(event); err != nil {
-			return err
-		}
+		return s.ApplyCreateSessionEvent(event)
 	case ShootEventType:
-		if err := s.ApplyShootEvent(event); err != nil {
-			return err
-		}
+		return s.ApplyShootEvent(event)
 	case DestroyShipEventType:
-		if err := s.ApplyDestroyShipEvent(event); err != nil {
+		return s.ApplyDestroyShipEvent(event)
+	case StatusChangedEventType:
+		return s.ApplyStatusChangedEvent(event)
+	case FleetPlacedEventType:
+		return s.ApplyFleetPlacedEvent(event)
+	case SalvoEventType:
+		return s.ApplySalvoEvent(event)
+	case SonarEventType, TorpedoEventType, BombEventType:
+		return s.ApplyWeaponEvent(event)
+	case RestoreSessionEventType:
+		// restoring from archive does not change the game
+		return nil
+	}
+
+	return fmt.Errorf("unknown event type %q", event.EventType)
+}
+
+// decodeEventData unmarshals event payload into v. Events which were
+// not stored yet hold data structs, those are round-tripped through JSON
+func decodeEventData(event *Event, v interface{}) error {
+	var body []byte
+	switch data := event.Data.(type) {
+	case string:
+		body = []byte(data)
+	case []byte:
+		body = data
+	case nil:
+		return errors.New("event has no data")
+	default:
+		encoded, err := event.EncodeData()
+		if err != nil {
 			return err
 		}
+		body = []byte(encoded)
 	}
 
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("cannot decode %T data: %v", event.Data, err)
+	}
 	return nil
 }
 
 // ApplyCreateSessionEvent add player and computer boards and their
 // IDs to newly created session struct
 func (s *Session) ApplyCreateSessionEvent(event *Event) error {
-	body := event.Data.(string)
 	var payload NewSessionEventData
-	if err := json.Unmarshal([]byte(body), &payload); err != nil {
+	if err := decodeEventData(event, &payload); err != nil {
 		return err
 	}
+	if payload.Player == nil || payload.Computer == nil {
+		return errors.New("session boards are missing")
+	}
+	if payload.Rules == nil {
+		return errors.New("session rules are missing")
+	}
 
+	for _, board := range []*Board{s.Computer, s.Player} {
+		board.Width, board.Height = payload.Rules.Width, payload.Rules.Height
+		board.NoTouching = payload.Rules.NoTouching
+		board.HiddenSinks = payload.Rules.HiddenSinks
+	}
 	s.Computer.Battleships = payload.Computer.Battleships
 	s.Player.Battleships = payload.Player.Battleships
+	s.Computer.Terrain = payload.Computer.Terrain
+	s.Player.Terrain = payload.Player.Terrain
+	s.PlayerID = payload.PlayerID
+	s.Rules = payload.Rules
+	s.Difficulty = payload.Difficulty
+	s.Status = StatusCreated
+	if payload.Status == StatusSetup {
+		s.Status = StatusSetup
+	}
+	s.Turn = SidePlayer
 	return nil
 }
 
 // ApplyShootEvent handles shooting cells
 func (s *Session) ApplyShootEvent(event *Event) error {
-	body := event.Data.(string)
 	var payload ShootEventData
-	if err := json.Unmarshal([]byte(body), &payload); err != nil {
+	if err := decodeEventData(event, &payload); err != nil {
 		return err
 	}
+	if !s.targetBoard(payload.IsComputer).Contains(payload.Cell) {
+		return fmt.Errorf("shot at (%d, %d) is outside of the board", payload.X, payload.Y)
+	}
 
-	if payload.IsComputer {
-		s.Player.RegisterShot(payload.Cell)
-	} else {
-		s.Computer.RegisterShot(payload.Cell)
+	if s.Status.IsFinished() {
+		return ErrGameOver
+	}
+	if payload.NextTurn != SidePlayer && payload.NextTurn != SideComputer {
+		return fmt.Errorf("unknown side %q", payload.NextTurn)
 	}
+
+	// history may hold repeated or out of turn shots written before
+	// moves were validated, those are replayed as they were accepted
+	s.RegisterShot(payload.Cell, payload.IsComputer)
+	s.Turn = payload.NextTurn
 	return nil
 }
 
 // ApplyDestroyShipEvent applies ship as dead if its all
 // cells are destroyed
 func (s *Session) ApplyDestroyShipEvent(event *Event) error {
-	body := event.Data.(string)
 	var payload DestroyShipEventData
-	if err := json.Unmarshal([]byte(body), &payload); err != nil {
+	if err := decodeEventData(event, &payload); err != nil {
 		return err
 	}
 
+	board := s.Player
 	if payload.IsComputer {
-		s.Computer.MarkShipIfDead(payload.ShipID)
-	} else {
-		s.Player.MarkShipIfDead(payload.ShipID)
+		board = s.Computer
+	}
+	if board.MarkShipIfDead(payload.ShipID) == nil {
+		return fmt.Errorf("ship %s does not exist or is not destroyed", payload.ShipID)
 	}
 	return nil
 }
 
 const (
-	NewSessionEventType  = "new_session"
-	ShootEventType       = "shoot"
-	DestroyShipEventType = "destroy_ship"
+	NewSessionEventType    = "new_session"
+	ShootEventType         = "shoot"
+	DestroyShipEventType   = "destroy_ship"
+	StatusChangedEventType = "status_changed"
+	FleetPlacedEventType   = "fleet_placed"
+	SalvoEventType         = "salvo"
+	SonarEventType         = "sonar_scan"
+	TorpedoEventType       = "torpedo"
+	BombEventType          = "bomb"
+
+	RestoreSessionEventType = "session_restored"
 )
 
 type NewSessionEventData struct {
 	Session
 }
 
-func CreateNewSessionEvent(session *Session) *Event {
+// newEvent creates event of given type at its current schema version
+func newEvent(aggregateID, eventType string, data interface{}) *Event {
 	return &Event{
-		AggregateID: session.ID,
-		Data:        session,
-		EventType:   NewSessionEventType,
-		CreatedAt:   time.Now(),
+		AggregateID:   aggregateID,
+		Data:          data,
+		EventType:     eventType,
+		CreatedAt:     time.Now(),
+		SchemaVersion: SchemaVersion(eventType),
 	}
 }
 
+func CreateNewSessionEvent(session *Session) *Event {
+	return newEvent(session.ID, NewSessionEventType, session)
+}
+
 type ShootEventData struct {
 	Cell
-	IsComputer bool `json:"is_computer"`
+	IsComputer bool   `json:"is_computer"`
+	NextTurn   string `json:"next_turn"` // side making the move after this shot
 }
 
-func CreateShootEvent(sessionID string, cell *Cell, isComputer bool) *Event {
-	return &Event{
-		AggregateID: sessionID,
-		Data: ShootEventData{
-			Cell:       *cell,
-			IsComputer: isComputer,
-		},
-		EventType: ShootEventType,
-		CreatedAt: time.Now(),
-	}
+func CreateShootEvent(sessionID string, cell *Cell, isComputer bool, nextTurn string) *Event {
+	return newEvent(sessionID, ShootEventType, ShootEventData{
+		Cell:       *cell,
+		IsComputer: isComputer,
+		NextTurn:   nextTurn,
+	})
+}
+
+type SalvoEventData struct {
+	Cells      []Cell `json:"cells"`
+	IsComputer bool   `json:"is_computer"`
+}
+
+// CreateSalvoEvent records all shots side fired in one turn
+func CreateSalvoEvent(sessionID string, cells []Cell, isComputer bool) *Event {
+	return newEvent(sessionID, SalvoEventType, SalvoEventData{
+		Cells:      cells,
+		IsComputer: isComputer,
+	})
+}
+
+// WeaponEventData is shared by events of special weapons, see UseWeapon
+// for creating them
+type WeaponEventData struct {
+	WeaponMove
+	IsComputer bool `json:"is_computer"`
 }
 
 type DestroyShipEventData struct {
@@ -172,13 +382,42 @@ type DestroyShipEventData struct {
 }
 
 func CreateDestroyShipEvent(sessionID string, shipID string, isComputer bool) *Event {
-	return &Event{
-		AggregateID: sessionID,
-		Data: DestroyShipEventData{
-			ShipID:     shipID,
-			IsComputer: isComputer,
-		},
-		EventType: DestroyShipEventType,
-		CreatedAt: time.Now(),
-	}
+	return newEvent(sessionID, DestroyShipEventType, DestroyShipEventData{
+		ShipID:     shipID,
+		IsComputer: isComputer,
+	})
+}
+
+type RestoreSessionEventData struct {
+	ArchivedAt time.Time `json:"archived_at"`
+}
+
+// CreateRestoreSessionEvent marks session brought back from archive
+func CreateRestoreSessionEvent(sessionID string, archivedAt time.Time) *Event {
+	return newEvent(sessionID, RestoreSessionEventType, RestoreSessionEventData{
+		ArchivedAt: archivedAt,
+	})
+}
+
+type StatusChangedEventData struct {
+	Status SessionStatus `json:"status"`
+}
+
+func CreateStatusChangedEvent(sessionID string, status SessionStatus) *Event {
+	return newEvent(sessionID, StatusChangedEventType, StatusChangedEventData{
+		Status: status,
+	})
+}
+
+type FleetPlacedEventData struct {
+	Ships     []*BattleShip `json:"ships"`
+	Confirmed bool          `json:"confirmed"` // fleet is final and setup is over
+}
+
+// CreateFleetPlacedEvent records player fleet placed during setup
+func CreateFleetPlacedEvent(sessionID string, ships []*BattleShip, confirmed bool) *Event {
+	return newEvent(sessionID, FleetPlacedEventType, FleetPlacedEventData{
+		Ships:     ships,
+		Confirmed: confirmed,
+	})
 }
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// redisMessage turns XADD arguments into entry as it is read back
+func redisMessage(id string, args *redis.XAddArgs) redis.XMessage {
+	values := make(map[string]interface{}, len(args.Values))
+	for key, value := range args.Values {
+		// redis replies with every field value as string
+		values[key] = fmt.Sprint(value)
+	}
+	return redis.XMessage{ID: id, Values: values}
+}
+
+func TestRedisStreamRoundTrip(t *testing.T) {
+	event := CreateShootEvent("session-id", &Cell{X: 2, Y: 7}, true, SidePlayer)
+
+	restored := DeserializeRedisStream("session-id", 3, redisMessage("1560000000000-0", event.SerializeRedisStream()))
+
+	if restored.AggregateID != event.AggregateID {
+		t.Errorf("expected aggregate ID %q, got %q", event.AggregateID, restored.AggregateID)
+	}
+	if restored.EventType != event.EventType {
+		t.Errorf("expected event type %q, got %q", event.EventType, restored.EventType)
+	}
+	if !restored.CreatedAt.Equal(event.CreatedAt) {
+		t.Errorf("expected created at %v, got %v", event.CreatedAt, restored.CreatedAt)
+	}
+	if restored.StreamID != "1560000000000-0" || restored.Sequence != 3 {
+		t.Errorf("expected stream position 1560000000000-0 #3, got %s #%d", restored.StreamID, restored.Sequence)
+	}
+	if restored.SchemaVersion != event.SchemaVersion {
+		t.Errorf("expected schema version %d, got %d", event.SchemaVersion, restored.SchemaVersion)
+	}
+	if data, _ := event.EncodeData(); restored.Data != data {
+		t.Errorf("expected data %v, got %v", data, restored.Data)
+	}
+}
+
+func TestRedisStreamLegacyCreatedAt(t *testing.T) {
+	createdAt := time.Date(2019, 6, 1, 12, 30, 0, 0, time.UTC)
+	binaryTime, _ := createdAt.MarshalBinary()
+
+	message := redis.XMessage{
+		ID: "1559392200000-0",
+		Values: map[string]interface{}{
+			EventTypeKey: ShootEventType,
+			DataKey:      `{"x":1,"y":1,"is_dead":false,"is_computer":false}`,
+			CreatedAtKey: string(binaryTime),
+		},
+	}
+	if restored := DeserializeRedisStream("session-id", 1, message); !restored.CreatedAt.Equal(createdAt) {
+		t.Errorf("expected binary created at %v, got %v", createdAt, restored.CreatedAt)
+	}
+
+	delete(message.Values, CreatedAtKey)
+	if restored := DeserializeRedisStream("session-id", 1, message); !restored.CreatedAt.Equal(createdAt) {
+		t.Errorf("expected created at from entry ID %v, got %v", createdAt, restored.CreatedAt)
+	}
+}
@@ -0,0 +1,281 @@
+package models
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/billyboar/battleships/helpers"
+)
+
+// ErrNoValidLayout is returned when fleet cannot be placed on the board
+var ErrNoValidLayout = errors.New("fleet cannot be placed on the board")
+
+// Rejection sampling draws up to sampleAttempts layouts before search
+// checks that fleet fits at all, fleets which do are then drawn up to
+// denseSampleAttempts times. Even seabattle preset, the densest one,
+// is drawn about once in 5000 attempts
+const (
+	sampleAttempts      = 2000
+	denseSampleAttempts = 200000
+)
+
+// terrainAttempts limits how many times random terrain is redrawn
+// when fleet does not fit around it
+const terrainAttempts = 10
+
+// placement is a single position of a ship on the board
+type placement struct {
+	head        Cell
+	isVertical  bool
+	orientation int
+	cells       []Cell
+}
+
+// layoutShip is a ship waiting to be placed together with its
+// candidate positions, ships of the same class share them
+type layoutShip struct {
+	class      ShipClass
+	placements []placement
+}
+
+// layout tracks cells taken by placed ships. Each cell counts ships
+// blocking it, so placement can be undone while backtracking
+type layout struct {
+	board   *Board
+	blocked []int
+	chosen  []int // index of placement chosen for each ship
+}
+
+// GenerateBoard creates new board with terrain and randomly placed fleet
+// of the rules. Every valid layout is equally likely, ErrNoValidLayout is
+// returned when fleet does not fit the board
+func GenerateBoard(isComputer bool, rules *RuleSet) (*Board, error) {
+	for attempt := 1; ; attempt++ {
+		board := NewBoard(isComputer)
+		board.Width, board.Height = rules.Width, rules.Height
+		board.NoTouching = rules.NoTouching
+		board.HiddenSinks = rules.HiddenSinks
+		board.generateTerrain(rules)
+
+		err := board.placeRandomFleet(rules)
+		if err == ErrNoValidLayout && rules.RandomTerrain > 0 && attempt < terrainAttempts {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return board, nil
+	}
+}
+
+// placeRandomFleet places ships of the rules at random
+func (b *Board) placeRandomFleet(rules *RuleSet) error {
+	ships := b.layoutShips(rules)
+	l := &layout{
+		board:   b,
+		blocked: make([]int, b.Width*b.Height),
+		chosen:  make([]int, len(ships)),
+	}
+
+	if _, err := l.choose(ships); err != nil {
+		return err
+	}
+
+	for i, ship := range ships {
+		battleShip, err := NewBattleShip(ship.class.Name, ship.class.Length)
+		if err != nil {
+			return err
+		}
+
+		placement := ship.placements[l.chosen[i]]
+		battleShip.IsVertical = placement.isVertical
+		battleShip.Shape = ship.class.Shape
+		battleShip.Orientation = placement.orientation
+		battleShip.BuildBody(placement.head)
+		b.Battleships = append(b.Battleships, battleShip)
+	}
+
+	return nil
+}
+
+// choose picks placement of every ship and reports if it was drawn
+// uniformly. Rejection sampling keeps layouts uniform, randomized search
+// is not as layouts reached by fewer choices come up more often, so its
+// layout is used only for custom fleets too dense to be drawn by chance
+func (l *layout) choose(ships []layoutShip) (uniform bool, err error) {
+	if l.draw(ships, sampleAttempts) {
+		return true, nil
+	}
+
+	l.reset()
+	if !l.search(ships, 0) {
+		return false, ErrNoValidLayout
+	}
+	searched := append([]int(nil), l.chosen...)
+
+	if l.draw(ships, denseSampleAttempts) {
+		return true, nil
+	}
+	copy(l.chosen, searched)
+	return false, nil
+}
+
+// draw samples layouts until one fits, at most attempts times
+func (l *layout) draw(ships []layoutShip, attempts int) bool {
+	for i := 0; i < attempts; i++ {
+		if l.sample(ships) {
+			return true
+		}
+	}
+	return false
+}
+
+// layoutShips expands fleet of the rules into ships, longest first
+func (b *Board) layoutShips(rules *RuleSet) []layoutShip {
+	classes := append([]ShipClass(nil), rules.Ships...)
+	sort.SliceStable(classes, func(i, j int) bool {
+		return classes[i].Length > classes[j].Length
+	})
+
+	var ships []layoutShip
+	for _, class := range classes {
+		placements := b.placements(class)
+		for i := 0; i < class.Count; i++ {
+			ships = append(ships, layoutShip{class: class, placements: placements})
+		}
+	}
+	return ships
+}
+
+// placements returns every position of ship of the class
+// on the board without ships
+func (b *Board) placements(class ShipClass) []placement {
+	blocked := b.blockedCells()
+	var placements []placement
+	for _, ship := range orientedShips(class.Length, class.Shape) {
+		for x := 0; x < b.Width; x++ {
+			for y := 0; y < b.Height; y++ {
+				head := Cell{X: x, Y: y}
+				cells := ship.BodyCells(head)
+				if b.fits(blocked, cells) {
+					placements = append(placements, placement{head, ship.IsVertical, ship.Orientation, cells})
+				}
+			}
+		}
+	}
+	return placements
+}
+
+// orientedShips returns ship in each of its distinct orientations,
+// shape is empty for straight ships
+func orientedShips(length int, shape []Cell) []BattleShip {
+	var ships []BattleShip
+	if len(shape) > 0 {
+		for _, orientation := range shapeOrientations(shape) {
+			ships = append(ships, BattleShip{Length: length, Shape: shape, Orientation: orientation})
+		}
+		return ships
+	}
+
+	ships = append(ships, BattleShip{Length: length})
+	// single cell ships look the same in both orientations
+	if length > 1 {
+		ships = append(ships, BattleShip{Length: length, IsVertical: true})
+	}
+	return ships
+}
+
+func (l *layout) reset() {
+	for i := range l.blocked {
+		l.blocked[i] = 0
+	}
+}
+
+// free reports if no placed ship blocks placement
+func (l *layout) free(p placement) bool {
+	for _, cell := range p.cells {
+		if l.blocked[cell.Y*l.board.Width+cell.X] > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// block marks cells of placement, and their neighbours with NoTouching
+// rule, as taken when delta is 1 and releases them when it is -1
+func (l *layout) block(p placement, delta int) {
+	for _, cell := range p.cells {
+		cells := []Cell{cell}
+		if l.board.NoTouching {
+			cells = append(cells, cell.Neighbours()...)
+		}
+
+		for _, blocked := range cells {
+			if l.board.Contains(blocked) {
+				l.blocked[blocked.Y*l.board.Width+blocked.X] += delta
+			}
+		}
+	}
+}
+
+// sample draws position of every ship at random and reports if
+// the ships ended up apart
+func (l *layout) sample(ships []layoutShip) bool {
+	l.reset()
+	for i, ship := range ships {
+		if len(ship.placements) == 0 {
+			return false
+		}
+
+		l.chosen[i] = helpers.GenerateRandomInt(len(ship.placements))
+		placement := ship.placements[l.chosen[i]]
+		if !l.free(placement) {
+			return false
+		}
+		l.block(placement, 1)
+	}
+	return true
+}
+
+// search places ships from i onwards trying positions in random order
+// and backtracks when a ship does not fit anywhere
+func (l *layout) search(ships []layoutShip, i int) bool {
+	if i == len(ships) {
+		return true
+	}
+
+	ship := ships[i]
+	order := make([]int, len(ship.placements))
+	for j := range order {
+		order[j] = j
+	}
+	helpers.Shuffle(len(order), func(a, b int) {
+		order[a], order[b] = order[b], order[a]
+	})
+
+	// ships of the same class are interchangeable, each next one is kept
+	// after the previous in the order to skip permutations of one layout
+	first := 0
+	if i > 0 && ships[i-1].class.Name == ship.class.Name {
+		first = l.chosen[i-1] + 1
+	}
+
+	for _, j := range order {
+		if j < first {
+			continue
+		}
+
+		placement := ship.placements[j]
+		if !l.free(placement) {
+			continue
+		}
+
+		l.chosen[i] = j
+		l.block(placement, 1)
+		if l.search(ships, i+1) {
+			return true
+		}
+		l.block(placement, -1)
+	}
+	return false
+}
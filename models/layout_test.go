@@ -0,0 +1,111 @@
+package models
+
+import (
+	"math"
+	"testing"
+)
+
+func TestGenerateBoardDenseFleet(t *testing.T) {
+	// nine boats on 5x5 board fit only on every other cell
+	rules := &RuleSet{Width: 5, Height: 5, NoTouching: true, Ships: []ShipClass{{Name: "boat", Length: 1, Count: 9}}}
+	board, err := GenerateBoard(false, rules)
+	if err != nil {
+		t.Fatal("expected dense fleet to be placed:", err)
+	}
+	for _, ship := range board.Battleships {
+		if cell := ship.Cells[0]; cell.X%2 != 0 || cell.Y%2 != 0 {
+			t.Errorf("unexpected boat at (%d, %d)", cell.X, cell.Y)
+		}
+	}
+
+	rules.Ships[0].Count = 10
+	if _, err := GenerateBoard(false, rules); err != ErrNoValidLayout {
+		t.Errorf("expected %v for fleet which does not fit, got %v", ErrNoValidLayout, err)
+	}
+}
+
+func TestGenerateBoardIsUniform(t *testing.T) {
+	rules := &RuleSet{Width: 5, Height: 5, Ships: []ShipClass{
+		{Name: "battleship", Length: 4, Count: 1},
+		{Name: "destroyer", Length: 2, Count: 1},
+	}}
+
+	// counting layouts with battleship along the edge, placing ships
+	// one after another would favour those as they leave more room
+	onEdge := func(cells []Cell) bool {
+		return cells[0].X == cells[3].X && (cells[0].X == 0 || cells[0].X == 4) ||
+			cells[0].Y == cells[3].Y && (cells[0].Y == 0 || cells[0].Y == 4)
+	}
+
+	board := NewBoard(false)
+	board.Width, board.Height = rules.Width, rules.Height
+	var total, edge float64
+	for _, battleship := range board.placements(rules.Ships[0]) {
+		for _, destroyer := range board.placements(rules.Ships[1]) {
+			l := &layout{board: board, blocked: make([]int, 25)}
+			l.block(battleship, 1)
+			if l.free(destroyer) {
+				total++
+				if onEdge(battleship.cells) {
+					edge++
+				}
+			}
+		}
+	}
+
+	const samples = 10000
+	sampledEdge := 0.0
+	for i := 0; i < samples; i++ {
+		board, err := GenerateBoard(false, rules)
+		if err != nil {
+			t.Fatal("failed to create a board:", err)
+		}
+		if onEdge(board.Battleships[0].Cells) {
+			sampledEdge++
+		}
+	}
+
+	expected, got := edge/total, sampledEdge/samples
+	if math.Abs(expected-got) > 0.02 {
+		t.Errorf("expected %.3f of layouts with battleship on the edge, got %.3f", expected, got)
+	}
+}
+
+func TestSeabattleLayoutsAreUniform(t *testing.T) {
+	rules, _ := RuleSetByName("seabattle")
+	board := NewBoard(false)
+	board.Width, board.Height = rules.Width, rules.Height
+	board.NoTouching = rules.NoTouching
+	ships := board.layoutShips(rules)
+	l := &layout{
+		board:   board,
+		blocked: make([]int, board.Width*board.Height),
+		chosen:  make([]int, len(ships)),
+	}
+
+	// battleship along the edge leaves more room for the rest of the
+	// fleet, it is there in about 62% of layouts while search puts it
+	// there in about 47%
+	const samples = 300
+	edge := 0.0
+	for i := 0; i < samples; i++ {
+		uniform, err := l.choose(ships)
+		if err != nil {
+			t.Fatal("failed to place fleet:", err)
+		}
+		if !uniform {
+			t.Fatal("expected seabattle fleet to be drawn uniformly")
+		}
+
+		for _, cell := range ships[0].placements[l.chosen[0]].cells {
+			if cell.X == 0 || cell.Y == 0 || cell.X == board.Width-1 || cell.Y == board.Height-1 {
+				edge++
+				break
+			}
+		}
+	}
+
+	if got := edge / samples; got < 0.52 {
+		t.Errorf("expected battleship on the edge in about 0.62 of layouts, got %.3f", got)
+	}
+}
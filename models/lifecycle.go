@@ -0,0 +1,118 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+)
+
+// SessionStatus is the stage of the game session
+type SessionStatus string
+
+// Session statuses
+const (
+	StatusSetup      SessionStatus = "setup" // player is placing the fleet
+	StatusCreated    SessionStatus = "created"
+	StatusInProgress SessionStatus = "in_progress"
+	StatusWon        SessionStatus = "won"  // player destroyed computer fleet
+	StatusLost       SessionStatus = "lost" // computer destroyed player fleet
+	StatusAbandoned  SessionStatus = "abandoned"
+	StatusForfeited  SessionStatus = "forfeited"
+)
+
+// Game sides
+const (
+	SidePlayer   = "player"
+	SideComputer = "computer"
+)
+
+// ErrGameOver is returned for moves in a finished session
+var ErrGameOver = errors.New("game is over")
+
+// statusTransitions lists statuses reachable from every status,
+// finished statuses have none
+var statusTransitions = map[SessionStatus][]SessionStatus{
+	StatusSetup:      {StatusCreated, StatusAbandoned, StatusForfeited},
+	StatusCreated:    {StatusInProgress, StatusAbandoned, StatusForfeited},
+	StatusInProgress: {StatusWon, StatusLost, StatusAbandoned, StatusForfeited},
+}
+
+// IsFinished reports if no more moves can be made in
+// a session with this status
+func (s SessionStatus) IsFinished() bool {
+	switch s {
+	case StatusSetup, StatusCreated, StatusInProgress:
+		return false
+	}
+	return true
+}
+
+// CanTransition reports if session in this status may move to next
+func (s SessionStatus) CanTransition(next SessionStatus) bool {
+	for _, status := range statusTransitions[s] {
+		if status == next {
+			return true
+		}
+	}
+	return false
+}
+
+// Winner returns side which won the game, empty while nobody has.
+// Computer wins games forfeited by the player
+func (s *Session) Winner() string {
+	switch s.Status {
+	case StatusWon:
+		return SidePlayer
+	case StatusLost, StatusForfeited:
+		return SideComputer
+	}
+	return ""
+}
+
+// IsOver reports if no more moves can be made. Sessions recorded
+// before game over events existed are over once a fleet is destroyed
+func (s *Session) IsOver() bool {
+	return s.Status.IsFinished() || s.Player.IsFleetDestroyed() || s.Computer.IsFleetDestroyed()
+}
+
+// ChangeStatus moves session to next status and returns
+// event recording it
+func (s *Session) ChangeStatus(next SessionStatus) (*Event, error) {
+	if err := s.changeStatus(next); err != nil {
+		return nil, err
+	}
+	return CreateStatusChangedEvent(s.ID, next), nil
+}
+
+func (s *Session) changeStatus(next SessionStatus) error {
+	if s.Status.IsFinished() {
+		return ErrGameOver
+	}
+	if !s.Status.CanTransition(next) {
+		return fmt.Errorf("session cannot move from %s to %s", s.Status, next)
+	}
+
+	s.Status = next
+	return nil
+}
+
+// FinishIfFleetDestroyed moves session to won or lost when either
+// fleet is destroyed and returns event recording it, nil otherwise
+func (s *Session) FinishIfFleetDestroyed() (*Event, error) {
+	if s.Computer.IsFleetDestroyed() {
+		return s.ChangeStatus(StatusWon)
+	}
+	if s.Player.IsFleetDestroyed() {
+		return s.ChangeStatus(StatusLost)
+	}
+	return nil, nil
+}
+
+// ApplyStatusChangedEvent moves session to recorded status
+func (s *Session) ApplyStatusChangedEvent(event *Event) error {
+	var payload StatusChangedEventData
+	if err := decodeEventData(event, &payload); err != nil {
+		return err
+	}
+
+	return s.changeStatus(payload.Status)
+}
@@ -0,0 +1,85 @@
+package models
+
+import "testing"
+
+func TestSessionLifecycle(t *testing.T) {
+	session, err := NewSession()
+	if err != nil {
+		t.Fatal("failed to create session:", err)
+	}
+
+	if session.Status != StatusCreated {
+		t.Fatalf("expected new session to be %s, got %s", StatusCreated, session.Status)
+	}
+	if _, err := session.ChangeStatus(StatusWon); err == nil {
+		t.Error("expected session not to be won before it starts")
+	}
+
+	for _, ship := range session.Player.Battleships {
+		for _, cell := range ship.Cells {
+			_, shipID := session.RegisterShot(cell, true)
+			session.Player.MarkShipIfDead(shipID)
+		}
+	}
+	if session.Status != StatusInProgress {
+		t.Errorf("expected shots to start the game, got %s", session.Status)
+	}
+
+	event, err := session.FinishIfFleetDestroyed()
+	if err != nil || event == nil {
+		t.Fatalf("expected game over event, got %v: %v", event, err)
+	}
+	if session.Status != StatusLost || session.Winner() != SideComputer {
+		t.Errorf("expected computer to win, got %s won by %q", session.Status, session.Winner())
+	}
+
+	if _, err := session.ChangeStatus(StatusForfeited); err != ErrGameOver {
+		t.Errorf("expected finished session to reject changes, got %v", err)
+	}
+	shot := Cell{X: 0, Y: 0}
+	if err := session.Apply(CreateShootEvent(session.ID, &shot, false, SideComputer)); err != ErrGameOver {
+		t.Errorf("expected shot after game over to fail replay, got %v", err)
+	}
+}
+
+func TestForfeitedSessionIsWonByComputer(t *testing.T) {
+	session, err := NewSession()
+	if err != nil {
+		t.Fatal("failed to create session:", err)
+	}
+
+	if _, err := session.ChangeStatus(StatusForfeited); err != nil {
+		t.Fatal("failed to forfeit session:", err)
+	}
+	if winner := session.Winner(); winner != SideComputer {
+		t.Errorf("expected computer to win forfeited game, got %q", winner)
+	}
+}
+
+func TestValidateShot(t *testing.T) {
+	session, err := NewSession()
+	if err != nil {
+		t.Fatal("failed to create session:", err)
+	}
+
+	shot := Cell{X: 3, Y: 4}
+	if _, _, err := session.Shoot(shot, true); err != ErrOutOfTurn {
+		t.Errorf("expected computer to wait for player, got %v", err)
+	}
+	if _, _, err := session.Shoot(Cell{X: 10, Y: 0}, false); err != ErrCellOutOfBoard {
+		t.Errorf("expected shot outside of the board to fail, got %v", err)
+	}
+	if _, _, err := session.Shoot(shot, false); err != nil {
+		t.Fatal("expected valid shot to succeed, got", err)
+	}
+	if _, _, err := session.Shoot(shot, true); err != nil {
+		t.Fatal("expected computer to answer, got", err)
+	}
+	if _, _, err := session.Shoot(shot, false); err != ErrAlreadyShot {
+		t.Errorf("expected repeated shot to fail, got %v", err)
+	}
+
+	if len(session.Computer.Shots) != 1 || len(session.Player.Shots) != 1 {
+		t.Errorf("expected one shot per board, got %v and %v", session.Computer.Shots, session.Player.Shots)
+	}
+}
@@ -0,0 +1,82 @@
+package models
+
+// Monte Carlo shot draws monteCarloSamples fleet layouts matching known
+// shots, monteCarloAttempts limits layouts drawn in total
+const (
+	monteCarloSamples  = 200
+	monteCarloAttempts = 5000
+)
+
+// monteCarloShot fires at cell taken by ships in most of random fleet
+// layouts matching known shots. Probability density is used when too
+// few layouts match
+func (b *Board) monteCarloShot(planned CellMap) *Cell {
+	known := b.knownCells(planned)
+	b.applySonar(known)
+	openHits := b.openHits()
+
+	// layouts are drawn on scratch board, where cells known to be empty
+	// are terrain and ships afloat are the whole fleet
+	scratch := NewBoard(b.IsComputer)
+	scratch.Width, scratch.Height = b.Width, b.Height
+	scratch.NoTouching = b.NoTouching
+	for x, column := range known {
+		for y := range column {
+			cell := Cell{X: x, Y: y}
+			if b.Contains(cell) && !openHits[x][y] && !planned[x][y] {
+				scratch.Terrain = append(scratch.Terrain, cell)
+			}
+		}
+	}
+
+	ships := scratch.layoutShips(&RuleSet{Ships: b.remainingClasses()})
+	l := &layout{
+		board:   scratch,
+		blocked: make([]int, b.Width*b.Height),
+		chosen:  make([]int, len(ships)),
+	}
+
+	density := make([]int, b.Width*b.Height)
+	samples := 0
+	for i := 0; i < monteCarloAttempts && samples < monteCarloSamples; i++ {
+		if !l.sample(ships) {
+			continue
+		}
+
+		// layout has to explain every hit of ships afloat
+		taken := CellMap{}
+		for j, ship := range ships {
+			for _, cell := range ship.placements[l.chosen[j]].cells {
+				taken.add(cell)
+			}
+		}
+		if !coversAll(taken, openHits) {
+			continue
+		}
+
+		samples++
+		for x, column := range taken {
+			for y := range column {
+				if !known[x][y] {
+					density[y*b.Width+x]++
+				}
+			}
+		}
+	}
+
+	if shot := b.densestCell(density, nil); shot != nil {
+		return shot
+	}
+	return b.calculateShot(planned)
+}
+
+func coversAll(cells, other CellMap) bool {
+	for x, column := range other {
+		for y := range column {
+			if !cells[x][y] {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,75 @@
+package models
+
+import "errors"
+
+// Move validation errors
+var (
+	ErrCellOutOfBoard = errors.New("cell is outside of the board")
+	ErrAlreadyShot    = errors.New("cell was already shot")
+	ErrOutOfTurn      = errors.New("it is not this side's turn")
+	ErrTerrainCell    = errors.New("cell is covered by terrain")
+)
+
+// sideName returns name of the side by its computer flag
+func sideName(isComputer bool) string {
+	if isComputer {
+		return SideComputer
+	}
+	return SidePlayer
+}
+
+// targetBoard returns board the side fires at
+func (s *Session) targetBoard(isComputer bool) *Board {
+	if isComputer {
+		return s.Player
+	}
+	return s.Computer
+}
+
+// validateMove checks if side may make a move now
+func (s *Session) validateMove(isComputer bool) error {
+	if s.IsOver() {
+		return ErrGameOver
+	}
+	if s.Status == StatusSetup {
+		return ErrFleetNotPlaced
+	}
+	if s.Turn != sideName(isComputer) {
+		return ErrOutOfTurn
+	}
+	return nil
+}
+
+// ValidateShot checks if side may fire at the cell of the opponent board
+func (s *Session) ValidateShot(shot Cell, isComputer bool) error {
+	if err := s.validateMove(isComputer); err != nil {
+		return err
+	}
+
+	target := s.targetBoard(isComputer)
+	if !target.Contains(shot) {
+		return ErrCellOutOfBoard
+	}
+	if target.IsTerrain(shot) {
+		return ErrTerrainCell
+	}
+	if target.HasShot(shot) {
+		return ErrAlreadyShot
+	}
+
+	return nil
+}
+
+// Shoot validates and registers shot of one side. With ExtraShotOnHit
+// rule side which hit a ship keeps the turn
+func (s *Session) Shoot(shot Cell, isComputer bool) (shotStatus bool, shipID string, err error) {
+	if err := s.ValidateShot(shot, isComputer); err != nil {
+		return false, "", err
+	}
+
+	shotStatus, shipID = s.RegisterShot(shot, isComputer)
+	if shotStatus && s.Rules.ExtraShotOnHit {
+		s.Turn = sideName(isComputer)
+	}
+	return shotStatus, shipID, nil
+}
@@ -0,0 +1,138 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Fleet placement errors
+var (
+	ErrNotInSetup     = errors.New("session is not in setup")
+	ErrFleetNotPlaced = errors.New("fleet is not placed yet")
+)
+
+// ShipPlacement is position of a ship chosen by the player
+type ShipPlacement struct {
+	Name       string `json:"name"` // ship class name
+	Head       Cell   `json:"head"`
+	IsVertical bool   `json:"is_vertical"`
+	// Orientation turns polyomino ship, see Orientations
+	Orientation int `json:"orientation"`
+}
+
+// Ship builds ship of the placement using class from the rules
+func (p ShipPlacement) Ship(rules *RuleSet) (*BattleShip, error) {
+	class := rules.ShipClass(p.Name)
+	if class == nil {
+		return nil, fmt.Errorf("%q is not a ship of the fleet", p.Name)
+	}
+
+	if p.Orientation < 0 || p.Orientation >= Orientations {
+		return nil, fmt.Errorf("orientation of %s must be between 0 and %d", p.Name, Orientations-1)
+	}
+
+	ship, err := NewBattleShip(class.Name, class.Length)
+	if err != nil {
+		return nil, err
+	}
+	ship.IsVertical = p.IsVertical
+	if len(class.Shape) > 0 {
+		ship.IsVertical = false
+		ship.Shape = class.Shape
+		ship.Orientation = p.Orientation
+	}
+	ship.BuildBody(p.Head)
+
+	return ship, nil
+}
+
+// NewSetupSession creates session whose player places own fleet,
+// see PlaceFleet
+func NewSetupSession(rules *RuleSet) (*Session, error) {
+	session, err := NewSessionWithRules(rules)
+	if err != nil {
+		return nil, err
+	}
+
+	session.Player.Battleships = nil
+	session.Status = StatusSetup
+	return session, nil
+}
+
+// PlaceFleet replaces player fleet with placed ships and returns event
+// recording it. Draft fleet may be incomplete, confirmed one has to
+// match the rules and ends the setup
+func (s *Session) PlaceFleet(placements []ShipPlacement, confirm bool) (*Event, error) {
+	if s.Status != StatusSetup {
+		return nil, ErrNotInSetup
+	}
+
+	ships := make([]*BattleShip, len(placements))
+	for i, placement := range placements {
+		ship, err := placement.Ship(s.Rules)
+		if err != nil {
+			return nil, err
+		}
+		ships[i] = ship
+	}
+
+	if err := s.placeFleet(ships, confirm); err != nil {
+		return nil, err
+	}
+	return CreateFleetPlacedEvent(s.ID, ships, confirm), nil
+}
+
+func (s *Session) placeFleet(ships []*BattleShip, confirmed bool) error {
+	if s.Status != StatusSetup {
+		return ErrNotInSetup
+	}
+
+	// ships are checked against each other on a scratch board
+	board := NewBoard(false)
+	board.Width, board.Height = s.Player.Width, s.Player.Height
+	board.NoTouching = s.Player.NoTouching
+	board.Terrain = s.Player.Terrain
+
+	counts := map[string]int{}
+	for _, ship := range ships {
+		class := s.Rules.ShipClass(ship.Name)
+		if class == nil || class.Length != ship.Length || len(ship.Cells) != ship.Length {
+			return fmt.Errorf("%q is not a ship of the fleet", ship.Name)
+		}
+
+		counts[ship.Name]++
+		if counts[ship.Name] > class.Count {
+			return fmt.Errorf("fleet has only %d %s ships", class.Count, class.Name)
+		}
+
+		if !board.CanPlace(ship.Cells) {
+			head := ship.Cells[0]
+			return fmt.Errorf("%s at (%d, %d) is off the board, on terrain or too close to another ship", ship.Name, head.X, head.Y)
+		}
+		board.Battleships = append(board.Battleships, ship)
+	}
+
+	if confirmed {
+		for _, class := range s.Rules.Ships {
+			if counts[class.Name] != class.Count {
+				return fmt.Errorf("fleet needs %d %s ships", class.Count, class.Name)
+			}
+		}
+	}
+
+	s.Player.Battleships = board.Battleships
+	if confirmed {
+		return s.changeStatus(StatusCreated)
+	}
+	return nil
+}
+
+// ApplyFleetPlacedEvent places player fleet recorded during setup
+func (s *Session) ApplyFleetPlacedEvent(event *Event) error {
+	var payload FleetPlacedEventData
+	if err := decodeEventData(event, &payload); err != nil {
+		return err
+	}
+
+	return s.placeFleet(payload.Ships, payload.Confirmed)
+}
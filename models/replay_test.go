@@ -0,0 +1,179 @@
+package models
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// streamFixture is a historical event stream which must keep
+// replaying to the same state whenever event payloads change
+type streamFixture struct {
+	Description string `json:"description"`
+	SessionID   string `json:"session_id"`
+	Rules       string `json:"rules"` // name of rule set stream replays with
+	Events      []struct {
+		EventType     string          `json:"event_type"`
+		SchemaVersion int             `json:"schema_version"`
+		CreatedAt     time.Time       `json:"created_at"`
+		Data          json.RawMessage `json:"data"`
+	} `json:"events"`
+	Expected map[string]struct {
+		Wounds      int      `json:"wounds"`
+		MissedShots int      `json:"missed_shots"`
+		DeadShips   []string `json:"dead_ships"`
+	} `json:"expected"`
+}
+
+func loadStreamFixture(t *testing.T, path string) (*streamFixture, []*Event) {
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fixture streamFixture
+	if err := json.Unmarshal(body, &fixture); err != nil {
+		t.Fatal("cannot decode fixture:", err)
+	}
+
+	events := make([]*Event, len(fixture.Events))
+	for i, event := range fixture.Events {
+		events[i] = &Event{
+			AggregateID:   fixture.SessionID,
+			Data:          string(event.Data),
+			EventType:     event.EventType,
+			CreatedAt:     event.CreatedAt,
+			Sequence:      i + 1,
+			SchemaVersion: event.SchemaVersion,
+		}
+	}
+
+	return &fixture, events
+}
+
+func TestReplayHistoricalStreams(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "streams", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no stream fixtures found")
+	}
+
+	for _, path := range paths {
+		fixture, events := loadStreamFixture(t, path)
+
+		session, err := BuildSessionEvents(events, fixture.SessionID, true)
+		if err != nil {
+			t.Errorf("%s: cannot replay stream: %v", path, err)
+			continue
+		}
+
+		if session.Rules == nil || session.Rules.Name != fixture.Rules {
+			t.Errorf("%s: expected %q rules, got %+v", path, fixture.Rules, session.Rules)
+		}
+		if session.Difficulty != DefaultDifficulty {
+			t.Errorf("%s: expected %q difficulty, got %q", path, DefaultDifficulty, session.Difficulty)
+		}
+
+		boards := map[string]*Board{
+			"player":   session.Player,
+			"computer": session.Computer,
+		}
+		for side, expected := range fixture.Expected {
+			board := boards[side]
+
+			if wounds := len(board.GetAllShipWounds()); wounds != expected.Wounds {
+				t.Errorf("%s: expected %d %s wounds, got %d", path, expected.Wounds, side, wounds)
+			}
+			if missed := len(board.MissedShots); missed != expected.MissedShots {
+				t.Errorf("%s: expected %d %s missed shots, got %d", path, expected.MissedShots, side, missed)
+			}
+
+			deadShips := []string{}
+			for _, ship := range board.GetDeadShips() {
+				deadShips = append(deadShips, ship.ID)
+			}
+			if strings.Join(deadShips, ",") != strings.Join(expected.DeadShips, ",") {
+				t.Errorf("%s: expected %s dead ships %v, got %v", path, side, expected.DeadShips, deadShips)
+			}
+		}
+	}
+}
+
+func TestUpcastChain(t *testing.T) {
+	const eventType = "test_upcast"
+	schemaVersions[eventType] = 3
+	defer delete(schemaVersions, eventType)
+
+	RegisterUpcaster(eventType, 1, func(data []byte) ([]byte, error) {
+		return []byte(strings.Replace(string(data), `"name"`, `"title"`, 1)), nil
+	})
+	RegisterUpcaster(eventType, 2, func(data []byte) ([]byte, error) {
+		return []byte(strings.Replace(string(data), "}", `,"rank":1}`, 1)), nil
+	})
+	defer delete(upcasters, upcasterKey{eventType, 1})
+	defer delete(upcasters, upcasterKey{eventType, 2})
+
+	event := &Event{EventType: eventType, Data: `{"name":"x"}`}
+	if err := Upcast(event); err != nil {
+		t.Fatal("cannot upcast event:", err)
+	}
+	if event.Data != `{"title":"x","rank":1}` || event.SchemaVersion != 3 {
+		t.Errorf("unexpected upcasted event v%d %v", event.SchemaVersion, event.Data)
+	}
+
+	event = &Event{EventType: eventType, Data: `{}`, SchemaVersion: 4}
+	if err := Upcast(event); err == nil {
+		t.Error("expected event from newer schema to be rejected")
+	}
+}
+
+func TestReplayReportsDamagedEvents(t *testing.T) {
+	session, err := NewSession()
+	if err != nil {
+		t.Fatal("failed to create session:", err)
+	}
+	sessionData, _ := json.Marshal(session)
+
+	events := []*Event{
+		{EventType: NewSessionEventType, Data: string(sessionData), Sequence: 1},
+		{EventType: ShootEventType, Data: `{"x":1,"y":1,"is_computer":false}`, Sequence: 2},
+		{EventType: ShootEventType, Data: 42, Sequence: 3},
+		{EventType: DestroyShipEventType, Data: `{"ship_id":"unknown"}`, Sequence: 4},
+	}
+
+	_, err = BuildSessionEvents(events, session.ID, true)
+	replayErr, ok := err.(*ReplayError)
+	if !ok {
+		t.Fatalf("expected replay error, got %v", err)
+	}
+	if replayErr.Index != 2 || replayErr.EventType != ShootEventType {
+		t.Errorf("expected shoot event at index 2 to fail, got %s at %d", replayErr.EventType, replayErr.Index)
+	}
+
+	lenient, err := BuildSessionEvents(events, session.ID, false)
+	if err != nil {
+		t.Fatal("expected lenient replay to succeed, got", err)
+	}
+	if len(lenient.ReplayErrors) != 2 {
+		t.Errorf("expected 2 skipped events, got %v", lenient.ReplayErrors)
+	}
+	if lenient.Version != len(events) {
+		t.Errorf("expected version %d, got %d", len(events), lenient.Version)
+	}
+
+	damaged := append([]*Event{{EventType: NewSessionEventType, Data: "{broken", Sequence: 1}}, events[1:]...)
+	if _, err := BuildSessionEvents(damaged, session.ID, false); err == nil {
+		t.Error("expected lenient replay to fail without new_session event")
+	} else if replayErr, ok := err.(*ReplayError); !ok || replayErr.Index != 0 {
+		t.Errorf("expected replay error at index 0, got %v", err)
+	}
+
+	if _, err := BuildSessionEvents(nil, session.ID, true); err != ErrSessionNotFound {
+		t.Errorf("expected session not found, got %v", err)
+	}
+}
@@ -0,0 +1,291 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// DefaultRuleSetName is used when session does not choose rules
+const DefaultRuleSetName = "default"
+
+// Board size limits of rule sets
+const (
+	MinBoardSize = 5
+	MaxBoardSize = 26
+)
+
+// ErrUnknownRuleSet is returned for rule set names without preset
+var ErrUnknownRuleSet = errors.New("unknown rule set")
+
+// ShipClass describes ships of single kind in a fleet
+type ShipClass struct {
+	Name   string `json:"name"`
+	Length int    `json:"length"` // number of cells
+	Count  int    `json:"count"`  // number of such ships in the fleet
+	// Shape lists cells of polyomino ship, straight ships have none
+	Shape []Cell `json:"shape,omitempty"`
+}
+
+// RuleSet describes board size and fleet of each side
+type RuleSet struct {
+	Name   string      `json:"name"`
+	Width  int         `json:"width"`
+	Height int         `json:"height"`
+	Ships  []ShipClass `json:"ships"`
+	// NoTouching forbids ships to touch each other, even diagonally
+	NoTouching bool `json:"no_touching,omitempty"`
+	// Salvo lets side fire a shot for each surviving ship per turn
+	Salvo bool `json:"salvo,omitempty"`
+	// ExtraShotOnHit keeps the turn with side which hit a ship,
+	// salvos always pass the turn
+	ExtraShotOnHit bool `json:"extra_shot_on_hit,omitempty"`
+	// HiddenSinks only tells hit or miss, sunk ships are not
+	// announced until the game is over
+	HiddenSinks bool `json:"hidden_sinks,omitempty"`
+	// Terrain lists cells covered on every board, RandomTerrain
+	// is the number of cells covered at random on each board
+	Terrain       []Cell `json:"terrain,omitempty"`
+	RandomTerrain int    `json:"random_terrain,omitempty"`
+	// Weapons holds ammo of special weapons each side gets
+	Weapons map[string]int `json:"weapons,omitempty"`
+}
+
+// ruleSetPresets holds built-in rule sets by name
+var ruleSetPresets = map[string]RuleSet{
+	// fleet the game was originally played with
+	DefaultRuleSetName: {
+		Width:  BoardRow,
+		Height: BoardRow,
+		Ships: []ShipClass{
+			{Name: "destroyer", Length: DestroyerLength, Count: 2},
+			{Name: "battleship", Length: BattleShipLength, Count: 1},
+		},
+	},
+	// Hasbro rules
+	"classic": {
+		Width:  BoardRow,
+		Height: BoardRow,
+		Ships: []ShipClass{
+			{Name: "carrier", Length: 5, Count: 1},
+			{Name: "battleship", Length: 4, Count: 1},
+			{Name: "cruiser", Length: 3, Count: 1},
+			{Name: "submarine", Length: 3, Count: 1},
+			{Name: "destroyer", Length: 2, Count: 1},
+		},
+	},
+	// small board for short games
+	"quick": {
+		Width:  6,
+		Height: 6,
+		Ships: []ShipClass{
+			{Name: "cruiser", Length: 3, Count: 1},
+			{Name: "destroyer", Length: 2, Count: 2},
+		},
+	},
+	"large": {
+		Width:  20,
+		Height: 15,
+		Ships: []ShipClass{
+			{Name: "carrier", Length: 5, Count: 2},
+			{Name: "battleship", Length: 4, Count: 2},
+			{Name: "cruiser", Length: 3, Count: 3},
+			{Name: "destroyer", Length: 2, Count: 4},
+		},
+	},
+	// classic fleet sailing around islands
+	"islands": {
+		Width:  BoardRow,
+		Height: BoardRow,
+		Ships: []ShipClass{
+			{Name: "carrier", Length: 5, Count: 1},
+			{Name: "battleship", Length: 4, Count: 1},
+			{Name: "cruiser", Length: 3, Count: 1},
+			{Name: "submarine", Length: 3, Count: 1},
+			{Name: "destroyer", Length: 2, Count: 1},
+		},
+		Terrain:       []Cell{{X: 4, Y: 4}, {X: 5, Y: 4}, {X: 4, Y: 5}, {X: 5, Y: 5}},
+		RandomTerrain: 6,
+	},
+	// polyomino ships
+	"shapes": {
+		Width:  BoardRow,
+		Height: BoardRow,
+		Ships: []ShipClass{
+			{Name: "cross", Length: 5, Count: 1, Shape: []Cell{{X: 1, Y: 0}, {X: 0, Y: 1}, {X: 1, Y: 1}, {X: 2, Y: 1}, {X: 1, Y: 2}}},
+			{Name: "carrier", Length: 4, Count: 1, Shape: []Cell{{X: 0, Y: 0}, {X: 1, Y: 0}, {X: 0, Y: 1}, {X: 1, Y: 1}}},
+			{Name: "hook", Length: 4, Count: 1, Shape: []Cell{{X: 0, Y: 0}, {X: 0, Y: 1}, {X: 0, Y: 2}, {X: 1, Y: 2}}},
+			{Name: "tee", Length: 4, Count: 1, Shape: []Cell{{X: 0, Y: 0}, {X: 1, Y: 0}, {X: 2, Y: 0}, {X: 1, Y: 1}}},
+			{Name: "destroyer", Length: 2, Count: 1},
+		},
+	},
+	// 1x4, 2x3, 3x2, 4x1 fleet
+	"seabattle": {
+		Width:  BoardRow,
+		Height: BoardRow,
+		Ships: []ShipClass{
+			{Name: "battleship", Length: 4, Count: 1},
+			{Name: "cruiser", Length: 3, Count: 2},
+			{Name: "destroyer", Length: 2, Count: 3},
+			{Name: "boat", Length: 1, Count: 4},
+		},
+		NoTouching: true,
+	},
+	// classic fleet with special weapons
+	"arsenal": {
+		Width:  BoardRow,
+		Height: BoardRow,
+		Ships: []ShipClass{
+			{Name: "carrier", Length: 5, Count: 1},
+			{Name: "battleship", Length: 4, Count: 1},
+			{Name: "cruiser", Length: 3, Count: 1},
+			{Name: "submarine", Length: 3, Count: 1},
+			{Name: "destroyer", Length: 2, Count: 1},
+		},
+		Weapons: map[string]int{WeaponSonar: 2, WeaponTorpedo: 1, WeaponBomb: 2},
+	},
+}
+
+// RuleSetByName returns copy of built-in rule set, empty name
+// selects the default one
+func RuleSetByName(name string) (*RuleSet, error) {
+	if name == "" {
+		name = DefaultRuleSetName
+	}
+
+	preset, ok := ruleSetPresets[name]
+	if !ok {
+		return nil, ErrUnknownRuleSet
+	}
+
+	rules := preset
+	rules.Name = name
+	rules.Ships = append([]ShipClass(nil), preset.Ships...)
+	rules.Terrain = append([]Cell(nil), preset.Terrain...)
+	if preset.Weapons != nil {
+		rules.Weapons = map[string]int{}
+		for weapon, ammo := range preset.Weapons {
+			rules.Weapons[weapon] = ammo
+		}
+	}
+	return &rules, nil
+}
+
+// DefaultRuleSet returns rules of the original game
+func DefaultRuleSet() *RuleSet {
+	rules, _ := RuleSetByName(DefaultRuleSetName)
+	return rules
+}
+
+// RuleSets returns all built-in rule sets ordered by name
+func RuleSets() []*RuleSet {
+	var names []string
+	for name := range ruleSetPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	rules := make([]*RuleSet, len(names))
+	for i, name := range names {
+		rules[i], _ = RuleSetByName(name)
+	}
+	return rules
+}
+
+// ShipClass returns ship class of the fleet with given name
+func (r *RuleSet) ShipClass(name string) *ShipClass {
+	for i := range r.Ships {
+		if r.Ships[i].Name == name {
+			return &r.Ships[i]
+		}
+	}
+	return nil
+}
+
+// FleetSize returns total number of ships of one side
+func (r *RuleSet) FleetSize() (size int) {
+	for _, class := range r.Ships {
+		size += class.Count
+	}
+	return
+}
+
+// Validate checks that fleet can be placed on the board
+func (r *RuleSet) Validate() error {
+	for _, size := range []int{r.Width, r.Height} {
+		if size < MinBoardSize || size > MaxBoardSize {
+			return fmt.Errorf("board sides must be between %d and %d", MinBoardSize, MaxBoardSize)
+		}
+	}
+	if r.FleetSize() == 0 {
+		return errors.New("fleet has no ships")
+	}
+
+	fleetCells := 0
+	for _, class := range r.Ships {
+		if class.Count < 0 {
+			return fmt.Errorf("%s count must not be negative", class.Name)
+		}
+		if len(class.Shape) > 0 {
+			if err := r.validateShape(class); err != nil {
+				return err
+			}
+		} else if class.Length < 1 || (class.Length > r.Width && class.Length > r.Height) {
+			return fmt.Errorf("%s length %d does not fit the board", class.Name, class.Length)
+		}
+		fleetCells += class.Length * class.Count
+	}
+
+	for _, cell := range r.Terrain {
+		if cell.X < 0 || cell.X >= r.Width || cell.Y < 0 || cell.Y >= r.Height {
+			return fmt.Errorf("terrain at (%d, %d) is outside of the board", cell.X, cell.Y)
+		}
+	}
+	if r.RandomTerrain < 0 {
+		return errors.New("random terrain must not be negative")
+	}
+	if fleetCells+len(r.Terrain)+r.RandomTerrain > r.Width*r.Height {
+		return errors.New("fleet does not fit the board")
+	}
+
+	for weapon, ammo := range r.Weapons {
+		if _, ok := weaponEventTypes[weapon]; !ok {
+			return fmt.Errorf("unknown weapon %q", weapon)
+		}
+		if ammo < 0 {
+			return fmt.Errorf("%s ammo must not be negative", weapon)
+		}
+	}
+	if r.Salvo && len(r.Weapons) > 0 {
+		return errors.New("special weapons cannot be used in salvo mode")
+	}
+	// salvo size gives away how many ships are afloat
+	if r.Salvo && r.HiddenSinks {
+		return errors.New("sinks cannot be hidden in salvo mode")
+	}
+	return nil
+}
+
+// validateShape checks that polyomino ship fits the board
+func (r *RuleSet) validateShape(class ShipClass) error {
+	if len(class.Shape) != class.Length {
+		return fmt.Errorf("%s length must match its shape", class.Name)
+	}
+	if err := validateShape(class.Shape); err != nil {
+		return fmt.Errorf("%s %v", class.Name, err)
+	}
+
+	width, height := 0, 0
+	for _, cell := range orient(class.Shape, 0) {
+		if cell.X >= width {
+			width = cell.X + 1
+		}
+		if cell.Y >= height {
+			height = cell.Y + 1
+		}
+	}
+	if (width > r.Width || height > r.Height) && (height > r.Width || width > r.Height) {
+		return fmt.Errorf("%s does not fit the board", class.Name)
+	}
+	return nil
+}
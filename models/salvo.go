@@ -0,0 +1,100 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrSalvoSize is returned for salvo with wrong number of shots
+var ErrSalvoSize = errors.New("salvo must have a shot for every surviving ship")
+
+// SalvoSize returns number of shots side fires per turn in salvo mode,
+// it is the number of ships the side has afloat
+func (s *Session) SalvoSize(isComputer bool) (size int) {
+	fleet := s.Player
+	if isComputer {
+		fleet = s.Computer
+	}
+
+	for _, ship := range fleet.Battleships {
+		if !ship.IsDead {
+			size++
+		}
+	}
+	return
+}
+
+// ValidateSalvo checks if side may fire all the shots in one turn
+func (s *Session) ValidateSalvo(shots []Cell, isComputer bool) error {
+	if len(shots) != s.SalvoSize(isComputer) {
+		return ErrSalvoSize
+	}
+
+	fired := CellMap{}
+	for _, shot := range shots {
+		if err := s.ValidateShot(shot, isComputer); err != nil {
+			return err
+		}
+		if fired[shot.X][shot.Y] {
+			return ErrAlreadyShot
+		}
+		fired.add(shot)
+	}
+	return nil
+}
+
+// ShootSalvo validates and registers all shots of the salvo. Returned
+// cells tell which shots hit, ships sunk by the salvo are marked after
+// all of the shots landed
+func (s *Session) ShootSalvo(shots []Cell, isComputer bool) (results []Cell, deadShips []*BattleShip, err error) {
+	if err := s.ValidateSalvo(shots, isComputer); err != nil {
+		return nil, nil, err
+	}
+
+	results, hitShips := s.registerSalvo(shots, isComputer)
+
+	target := s.targetBoard(isComputer)
+	for _, shipID := range hitShips {
+		if deadShip := target.MarkShipIfDead(shipID); deadShip != nil {
+			deadShips = append(deadShips, deadShip)
+		}
+	}
+	return results, deadShips, nil
+}
+
+// registerSalvo registers shots and returns them with hit flag set
+// together with IDs of ships which were hit
+func (s *Session) registerSalvo(shots []Cell, isComputer bool) (results []Cell, hitShips []string) {
+	seen := map[string]bool{}
+	for _, shot := range shots {
+		isHit, shipID := s.RegisterShot(shot, isComputer)
+		results = append(results, Cell{X: shot.X, Y: shot.Y, IsDead: isHit})
+
+		if isHit && !seen[shipID] {
+			seen[shipID] = true
+			hitShips = append(hitShips, shipID)
+		}
+	}
+	return
+}
+
+// ApplySalvoEvent registers shots fired in a single salvo
+func (s *Session) ApplySalvoEvent(event *Event) error {
+	var payload SalvoEventData
+	if err := decodeEventData(event, &payload); err != nil {
+		return err
+	}
+	if s.Status.IsFinished() {
+		return ErrGameOver
+	}
+
+	target := s.targetBoard(payload.IsComputer)
+	for _, shot := range payload.Cells {
+		if !target.Contains(shot) {
+			return fmt.Errorf("shot at (%d, %d) is outside of the board", shot.X, shot.Y)
+		}
+	}
+
+	s.registerSalvo(payload.Cells, payload.IsComputer)
+	return nil
+}
@@ -6,30 +6,75 @@ import (
 
 // Session contains each board for computer and player
 type Session struct {
-	Player   *Board `json:"player"`
-	Computer *Board `json:"computer"`
-	ID       string `json:"id"`
+	Player   *Board        `json:"player"`
+	Computer *Board        `json:"computer"`
+	ID       string        `json:"id"`
+	PlayerID string        `json:"player_id,omitempty"` // player owning the session
+	Status   SessionStatus `json:"status"`
+	Turn     string        `json:"turn"`  // side making the next move
+	Rules    *RuleSet      `json:"rules"` // board and fleet the game is played with
+	Version  int           `json:"-"`     // number of events session is built from
+	StreamID string        `json:"-"`     // stream ID of the last applied event
+
+	// Difficulty selects shot strategy of the computer, see ShotStrategy
+	Difficulty string `json:"difficulty"`
+
+	// WeaponsUsed counts special weapons fired by each side
+	WeaponsUsed map[string]map[string]int `json:"weapons_used,omitempty"`
+
+	// ReplayErrors lists events skipped by lenient replay
+	ReplayErrors []*ReplayError `json:"-"`
 }
 
 // NewSession creates new session with boards
-// initialized
+// initialized by default rules
 func NewSession() (*Session, error) {
+	return NewSessionWithRules(DefaultRuleSet())
+}
+
+// NewSessionWithRules creates new session with boards
+// initialized by given rules
+func NewSessionWithRules(rules *RuleSet) (*Session, error) {
+	if err := rules.Validate(); err != nil {
+		return nil, err
+	}
+
 	id, err := uuid.NewV4()
 	if err != nil {
 		return nil, err
 	}
-	playerBoard, err := GenerateBoard(false)
+	playerBoard, err := GenerateBoard(false, rules)
 	if err != nil {
 		return nil, err
 	}
 
-	computerBoard, err := GenerateBoard(true)
+	computerBoard, err := GenerateBoard(true, rules)
 	if err != nil {
 		return nil, err
 	}
 	return &Session{
-		Player:   playerBoard,
-		Computer: computerBoard,
-		ID:       id.String(),
+		Player:     playerBoard,
+		Computer:   computerBoard,
+		ID:         id.String(),
+		Status:     StatusCreated,
+		Turn:       SidePlayer,
+		Rules:      rules,
+		Difficulty: DefaultDifficulty,
 	}, nil
 }
+
+// RegisterShot registers shot of one side on the board of the other
+// side and passes the turn, first shot starts the game. Shots are not
+// validated, see Shoot
+func (s *Session) RegisterShot(shot Cell, isComputer bool) (shotStatus bool, shipID string) {
+	s.passTurn(isComputer)
+	return s.targetBoard(isComputer).RegisterShot(shot)
+}
+
+// passTurn ends move of the side, first move starts the game
+func (s *Session) passTurn(isComputer bool) {
+	if s.Status == StatusCreated {
+		s.Status = StatusInProgress
+	}
+	s.Turn = sideName(!isComputer)
+}
@@ -0,0 +1,127 @@
+package models
+
+import (
+	"errors"
+	"sort"
+)
+
+// Orientations is the number of ways shape can be turned, four
+// rotations of the shape and four of its mirror image
+const Orientations = 8
+
+// orient rotates shape clockwise by quarter turns, orientations from 4
+// on are mirrored first. Result is moved to start at (0, 0) and sorted
+func orient(shape []Cell, orientation int) []Cell {
+	cells := make([]Cell, len(shape))
+	for i, cell := range shape {
+		x, y := cell.X, cell.Y
+		if orientation >= 4 {
+			x = -x
+		}
+		for turn := 0; turn < orientation%4; turn++ {
+			x, y = -y, x
+		}
+		cells[i] = Cell{X: x, Y: y}
+	}
+
+	return normalizeShape(cells)
+}
+
+// normalizeShape moves cells to start at (0, 0) and sorts them by rows
+func normalizeShape(cells []Cell) []Cell {
+	if len(cells) == 0 {
+		return cells
+	}
+
+	minX, minY := cells[0].X, cells[0].Y
+	for _, cell := range cells {
+		if cell.X < minX {
+			minX = cell.X
+		}
+		if cell.Y < minY {
+			minY = cell.Y
+		}
+	}
+
+	for i := range cells {
+		cells[i].X -= minX
+		cells[i].Y -= minY
+	}
+	sort.Slice(cells, func(i, j int) bool {
+		if cells[i].Y != cells[j].Y {
+			return cells[i].Y < cells[j].Y
+		}
+		return cells[i].X < cells[j].X
+	})
+	return cells
+}
+
+// shapeOrientations returns orientations giving distinct cells, symmetric
+// shapes look the same in some of them
+func shapeOrientations(shape []Cell) []int {
+	var orientations []int
+	var seen [][]Cell
+	for orientation := 0; orientation < Orientations; orientation++ {
+		cells := orient(shape, orientation)
+
+		isNew := true
+		for _, other := range seen {
+			if sameCells(cells, other) {
+				isNew = false
+				break
+			}
+		}
+		if isNew {
+			seen = append(seen, cells)
+			orientations = append(orientations, orientation)
+		}
+	}
+	return orientations
+}
+
+func sameCells(a, b []Cell) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Compare(&b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// validateShape checks that shape cells are distinct and connected
+// by their sides
+func validateShape(shape []Cell) error {
+	cells := CellMap{}
+	for _, cell := range shape {
+		if cells[cell.X][cell.Y] {
+			return errors.New("shape has duplicate cells")
+		}
+		cells.add(cell)
+	}
+
+	// walking from the first cell has to reach every other one
+	reached := CellMap{}
+	reached.add(shape[0])
+	queue := []Cell{shape[0]}
+	count := 1
+	for len(queue) > 0 {
+		cell := queue[0]
+		queue = queue[1:]
+
+		for _, next := range cell.Sides() {
+			if cells[next.X][next.Y] && !reached[next.X][next.Y] {
+				reached.add(next)
+				queue = append(queue, next)
+				count++
+			}
+		}
+	}
+
+	if count != len(shape) {
+		return errors.New("shape cells are not connected")
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package models
+
+import "testing"
+
+func TestShapeOrientations(t *testing.T) {
+	rules, _ := RuleSetByName("shapes")
+
+	expected := map[string]int{"cross": 1, "carrier": 1, "hook": 8, "tee": 4}
+	for name, count := range expected {
+		if orientations := shapeOrientations(rules.ShipClass(name).Shape); len(orientations) != count {
+			t.Errorf("expected %s to have %d orientations, got %d", name, count, len(orientations))
+		}
+	}
+
+	// hook turned clockwise lies on its side
+	ship, err := ShipPlacement{Name: "hook", Head: Cell{X: 3, Y: 3}, Orientation: 1}.Ship(rules)
+	if err != nil {
+		t.Fatal("cannot place hook:", err)
+	}
+	want := []Cell{{X: 3, Y: 3}, {X: 4, Y: 3}, {X: 5, Y: 3}, {X: 3, Y: 4}}
+	if !sameCells(ship.Cells, want) {
+		t.Errorf("expected hook at %v, got %v", want, ship.Cells)
+	}
+
+	if err := validateShape([]Cell{{X: 0, Y: 0}, {X: 1, Y: 1}}); err == nil {
+		t.Error("expected shape of cells touching by corners to be rejected")
+	}
+}
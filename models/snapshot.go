@@ -0,0 +1,73 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// SnapshotSchemaVersion is the version of Session shape stored in
+// snapshots. Bump it whenever Session or Board fields change, older
+// snapshots are then ignored and sessions are rebuilt from events
+const SnapshotSchemaVersion = 11
+
+// Snapshot is a session state covering first Version events of its
+// stream, so only events appended later have to be replayed
+type Snapshot struct {
+	SessionID string    `json:"session_id"`
+	Version   int       `json:"version"`   // number of events covered
+	StreamID  string    `json:"stream_id"` // stream ID of the last covered event
+	Session   *Session  `json:"session"`
+	CreatedAt time.Time `json:"created_at"`
+
+	SchemaVersion int `json:"schema_version"`
+}
+
+// NewSnapshot captures current state of the session
+func NewSnapshot(session *Session) *Snapshot {
+	return &Snapshot{
+		SessionID: session.ID,
+		Version:   session.Version,
+		StreamID:  session.StreamID,
+		Session:   session,
+		CreatedAt: time.Now(),
+
+		SchemaVersion: SnapshotSchemaVersion,
+	}
+}
+
+// DecodeSnapshot parses snapshot saved with Snapshot.Encode
+func DecodeSnapshot(data []byte) (*Snapshot, error) {
+	var snapshot Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// Encode serializes snapshot to JSON
+func (s *Snapshot) Encode() ([]byte, error) {
+	return json.Marshal(s)
+}
+
+// IsCurrent reports if snapshot was taken with current Session shape
+func (s *Snapshot) IsCurrent() bool {
+	return s.SchemaVersion == SnapshotSchemaVersion
+}
+
+// BuildSessionFromSnapshot restores session from snapshot and applies
+// events appended after it was taken, see BuildSessionEvents
+func BuildSessionFromSnapshot(snapshot *Snapshot, events []*Event, strict bool) (*Session, error) {
+	session := snapshot.Session
+	if session == nil || session.Player == nil || session.Computer == nil {
+		return nil, errors.New("snapshot has no session state")
+	}
+	session.Version = snapshot.Version
+	session.StreamID = snapshot.StreamID
+
+	if err := session.ApplyEvents(events, strict); err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
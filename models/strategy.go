@@ -0,0 +1,158 @@
+package models
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/billyboar/battleships/helpers"
+)
+
+// Difficulties of the computer, each plays with the shot strategy
+// of the same name
+const (
+	DifficultyRandom      = "random"
+	DifficultyHuntTarget  = "hunt_target"
+	DifficultyParity      = "parity"
+	DifficultyProbability = "probability"
+	DifficultyMonteCarlo  = "monte_carlo"
+
+	DefaultDifficulty = DifficultyProbability
+)
+
+// ErrUnknownDifficulty is returned for difficulty without shot strategy
+var ErrUnknownDifficulty = errors.New("unknown difficulty")
+
+// ShotStrategy picks computer shots at the board. It may only use what
+// shooter knows, that is shots fired, ships announced as sunk and sonar
+type ShotStrategy interface {
+	// Shot returns next cell to fire at, planned cells are going to be
+	// fired at in the same salvo. Nil is returned when no cell is left
+	Shot(board *Board, planned CellMap) *Cell
+}
+
+// ShotStrategyFunc adapts function to ShotStrategy
+type ShotStrategyFunc func(board *Board, planned CellMap) *Cell
+
+// Shot calls f(board, planned)
+func (f ShotStrategyFunc) Shot(board *Board, planned CellMap) *Cell {
+	return f(board, planned)
+}
+
+var shotStrategies = map[string]ShotStrategy{
+	DifficultyRandom:      ShotStrategyFunc((*Board).randomShot),
+	DifficultyHuntTarget:  ShotStrategyFunc((*Board).huntTargetShot),
+	DifficultyParity:      ShotStrategyFunc((*Board).parityShot),
+	DifficultyProbability: ShotStrategyFunc((*Board).calculateShot),
+	DifficultyMonteCarlo:  ShotStrategyFunc((*Board).monteCarloShot),
+}
+
+// RegisterShotStrategy adds strategy computer plays with at difficulty
+// of given name
+func RegisterShotStrategy(difficulty string, strategy ShotStrategy) {
+	shotStrategies[difficulty] = strategy
+}
+
+// ShotStrategyByName returns strategy of the difficulty, empty name
+// selects the default one
+func ShotStrategyByName(difficulty string) (ShotStrategy, error) {
+	if difficulty == "" {
+		difficulty = DefaultDifficulty
+	}
+
+	strategy, ok := shotStrategies[difficulty]
+	if !ok {
+		return nil, ErrUnknownDifficulty
+	}
+	return strategy, nil
+}
+
+// Difficulties returns names of all difficulties ordered by name
+func Difficulties() []string {
+	var names []string
+	for name := range shotStrategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// shotStrategy returns strategy of session difficulty, sessions with
+// difficulty which is not registered anymore play the default one
+func (s *Session) shotStrategy() ShotStrategy {
+	strategy, err := ShotStrategyByName(s.Difficulty)
+	if err != nil {
+		strategy, _ = ShotStrategyByName(DefaultDifficulty)
+	}
+	return strategy
+}
+
+// ComputerShot picks next computer shot at player board, nil when
+// every cell was already fired at
+func (s *Session) ComputerShot() *Cell {
+	return s.shotStrategy().Shot(s.Player, CellMap{})
+}
+
+// ComputerSalvo plans computer salvo at player board
+func (s *Session) ComputerSalvo() []Cell {
+	return s.Player.planSalvo(s.shotStrategy(), s.SalvoSize(true))
+}
+
+// randomShot fires at random cell which was not shot yet
+func (b *Board) randomShot(planned CellMap) *Cell {
+	known := b.knownCells(planned)
+	b.applySonar(known)
+
+	var cells []Cell
+	for x := 0; x < b.Width; x++ {
+		for y := 0; y < b.Height; y++ {
+			if !known[x][y] {
+				cells = append(cells, Cell{X: x, Y: y})
+			}
+		}
+	}
+	if len(cells) == 0 {
+		return nil
+	}
+	return &cells[helpers.GenerateRandomInt(len(cells))]
+}
+
+// parityShot hunts only on cells spaced by the shortest ship afloat,
+// every such ship covers one of them, and finishes hit ships the way
+// hunt and target does
+func (b *Board) parityShot(planned CellMap) *Cell {
+	if b.hasTargets() {
+		return b.huntTargetShot(planned)
+	}
+
+	known := b.knownCells(planned)
+	b.applySonar(known)
+
+	spacing := 0
+	for _, class := range b.remainingClasses() {
+		length := class.Length
+		// polyomino of two or more cells always covers both colors
+		// of checkerboard
+		if len(class.Shape) > 0 && length > 2 {
+			length = 2
+		}
+		if spacing == 0 || length < spacing {
+			spacing = length
+		}
+	}
+	if spacing < 1 {
+		spacing = 1
+	}
+
+	var cells []Cell
+	for x := 0; x < b.Width; x++ {
+		for y := 0; y < b.Height; y++ {
+			if !known[x][y] && (x+y)%spacing == 0 {
+				cells = append(cells, Cell{X: x, Y: y})
+			}
+		}
+	}
+	if len(cells) == 0 {
+		return b.huntTargetShot(planned)
+	}
+	return &cells[helpers.GenerateRandomInt(len(cells))]
+}
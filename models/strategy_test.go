@@ -0,0 +1,93 @@
+package models
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestShotStrategies(t *testing.T) {
+	legacyPaths, err := filepath.Glob(filepath.Join("testdata", "streams", "v1_*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, difficulty := range Difficulties() {
+		strategy, err := ShotStrategyByName(difficulty)
+		if err != nil {
+			t.Fatalf("%s: %v", difficulty, err)
+		}
+
+		boards := map[string]*Board{}
+		for _, name := range []string{"classic", "seabattle", "islands", "shapes"} {
+			rules, _ := RuleSetByName(name)
+			board, err := GenerateBoard(false, rules)
+			if err != nil {
+				t.Fatal("failed to create a board:", err)
+			}
+			boards[name] = board
+		}
+		// boards of sessions recorded before rule sets have unnamed ships
+		for _, path := range legacyPaths {
+			fixture, events := loadStreamFixture(t, path)
+			session, err := BuildSessionEvents(events, fixture.SessionID, true)
+			if err != nil {
+				t.Fatalf("%s: cannot replay stream: %v", path, err)
+			}
+			boards[path] = session.Player
+		}
+
+		for name, board := range boards {
+			shots := playOut(t, board, func() *Cell {
+				shot := strategy.Shot(board, CellMap{})
+				if shot != nil && (!board.Contains(*shot) || board.HasShot(*shot) || board.IsTerrain(*shot)) {
+					t.Fatalf("%s on %s fires at (%d, %d) which cannot be shot", difficulty, name, shot.X, shot.Y)
+				}
+				return shot
+			})
+			if shots > board.Width*board.Height {
+				t.Errorf("%s on %s took %d shots", difficulty, name, shots)
+			}
+		}
+	}
+
+	if _, err := ShotStrategyByName("impossible"); err != ErrUnknownDifficulty {
+		t.Errorf("expected %v, got %v", ErrUnknownDifficulty, err)
+	}
+}
+
+func TestParityShot(t *testing.T) {
+	rules, _ := RuleSetByName("classic")
+	board, err := GenerateBoard(false, rules)
+	if err != nil {
+		t.Fatal("failed to create a board:", err)
+	}
+
+	// destroyer of length 2 is afloat, so hunting stays on one color
+	for i := 0; i < 20; i++ {
+		shot := board.parityShot(CellMap{})
+		if (shot.X+shot.Y)%2 != 0 {
+			t.Fatalf("expected shot on parity cell, got (%d, %d)", shot.X, shot.Y)
+		}
+	}
+}
+
+func TestComputerSalvo(t *testing.T) {
+	session, err := NewSession()
+	if err != nil {
+		t.Fatal("failed to create session:", err)
+	}
+	session.Player.RegisterShot(session.Player.Battleships[0].Cells[0])
+
+	shots := session.ComputerSalvo()
+	if size := session.SalvoSize(true); len(shots) != size {
+		t.Fatalf("expected %d shots, got %d", size, len(shots))
+	}
+
+	fired := CellMap{}
+	for _, shot := range shots {
+		if fired[shot.X][shot.Y] || session.Player.HasShot(shot) {
+			t.Errorf("salvo fires at (%d, %d) twice", shot.X, shot.Y)
+		}
+		fired.add(shot)
+	}
+}
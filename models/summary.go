@@ -0,0 +1,64 @@
+package models
+
+import (
+	"time"
+)
+
+// SessionSummary is the index entry of a session, kept up to date by
+// event stores on every append
+type SessionSummary struct {
+	ID           string        `json:"id"`
+	PlayerID     string        `json:"player_id"` // player owning the session
+	Status       SessionStatus `json:"status"`
+	CreatedAt    time.Time     `json:"created_at"`
+	LastActivity time.Time     `json:"last_activity"`
+	EventCount   int           `json:"event_count"`
+}
+
+// NewSessionSummary creates empty summary of a session
+func NewSessionSummary(sessionID string) *SessionSummary {
+	return &SessionSummary{
+		ID: sessionID,
+	}
+}
+
+// Update moves summary past appended event
+func (s *SessionSummary) Update(event *Event) {
+	s.EventCount++
+	if event.CreatedAt.After(s.LastActivity) {
+		s.LastActivity = event.CreatedAt
+	}
+
+	switch event.EventType {
+	case NewSessionEventType:
+		s.CreatedAt = event.CreatedAt
+		s.Status = StatusCreated
+
+		// only owner and status are needed, boards are skipped
+		var payload struct {
+			PlayerID string        `json:"player_id"`
+			Status   SessionStatus `json:"status"`
+		}
+		decodeEventData(event, &payload)
+		s.PlayerID = payload.PlayerID
+		if payload.Status == StatusSetup {
+			s.Status = StatusSetup
+		}
+	case ShootEventType, SalvoEventType, SonarEventType, TorpedoEventType, BombEventType:
+		if s.Status == StatusCreated {
+			s.Status = StatusInProgress
+		}
+	case FleetPlacedEventType:
+		var payload struct {
+			Confirmed bool `json:"confirmed"`
+		}
+		if err := decodeEventData(event, &payload); err == nil && payload.Confirmed {
+			s.Status = StatusCreated
+		}
+	case StatusChangedEventType:
+		var payload StatusChangedEventData
+		if err := decodeEventData(event, &payload); err == nil {
+			s.Status = payload.Status
+		}
+	}
+}
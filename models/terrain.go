@@ -0,0 +1,37 @@
+package models
+
+import "github.com/billyboar/battleships/helpers"
+
+// generateTerrain covers fixed terrain cells of the rules and given
+// number of random ones
+func (b *Board) generateTerrain(rules *RuleSet) {
+	b.Terrain = append([]Cell(nil), rules.Terrain...)
+
+	var free []Cell
+	for x := 0; x < b.Width; x++ {
+		for y := 0; y < b.Height; y++ {
+			if cell := (Cell{X: x, Y: y}); !b.IsTerrain(cell) {
+				free = append(free, cell)
+			}
+		}
+	}
+
+	helpers.Shuffle(len(free), func(i, j int) {
+		free[i], free[j] = free[j], free[i]
+	})
+	if rules.RandomTerrain < len(free) {
+		free = free[:rules.RandomTerrain]
+	}
+	b.Terrain = append(b.Terrain, free...)
+}
+
+// IsTerrain reports if cell is covered by terrain, such cells
+// cannot hold ships and cannot be shot at
+func (b *Board) IsTerrain(cell Cell) bool {
+	for _, terrain := range b.Terrain {
+		if terrain.Compare(&cell) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,153 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Upcaster transforms JSON payload of an event from one schema
+// version into the next one
+type Upcaster func(data []byte) ([]byte, error)
+
+type upcasterKey struct {
+	eventType   string
+	fromVersion int
+}
+
+// schemaVersions holds current payload version of every event type.
+// Bump the version and register an upcaster from the previous one
+// whenever payload shape changes
+var schemaVersions = map[string]int{
+	NewSessionEventType:    3,
+	ShootEventType:         2,
+	DestroyShipEventType:   1,
+	StatusChangedEventType: 1,
+	FleetPlacedEventType:   1,
+	SalvoEventType:         1,
+	SonarEventType:         1,
+	TorpedoEventType:       1,
+	BombEventType:          1,
+
+	RestoreSessionEventType: 1,
+}
+
+var upcasters = map[upcasterKey]Upcaster{
+	{NewSessionEventType, 1}: addDefaultRules,
+	{NewSessionEventType, 2}: addDefaultDifficulty,
+	{ShootEventType, 1}:      addNextTurn,
+}
+
+// addDefaultRules upcasts new_session payload from version 1, sessions
+// created before rule sets were always played with default rules
+func addDefaultRules(data []byte) ([]byte, error) {
+	var payload map[string]json.RawMessage
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, err
+	}
+	if _, ok := payload["rules"]; ok {
+		return data, nil
+	}
+
+	rules, err := json.Marshal(DefaultRuleSet())
+	if err != nil {
+		return nil, err
+	}
+	payload["rules"] = rules
+	return json.Marshal(payload)
+}
+
+// addDefaultDifficulty upcasts new_session payload from version 2,
+// computer difficulty could not be chosen before
+func addDefaultDifficulty(data []byte) ([]byte, error) {
+	var payload map[string]json.RawMessage
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, err
+	}
+	if _, ok := payload["difficulty"]; ok {
+		return data, nil
+	}
+
+	difficulty, err := json.Marshal(DefaultDifficulty)
+	if err != nil {
+		return nil, err
+	}
+	payload["difficulty"] = difficulty
+	return json.Marshal(payload)
+}
+
+// addNextTurn upcasts shoot payload from version 1, turn always
+// passed to the other side before extra shots existed
+func addNextTurn(data []byte) ([]byte, error) {
+	var payload map[string]json.RawMessage
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, err
+	}
+	if _, ok := payload["next_turn"]; ok {
+		return data, nil
+	}
+
+	var isComputer bool
+	if raw, ok := payload["is_computer"]; ok {
+		if err := json.Unmarshal(raw, &isComputer); err != nil {
+			return nil, err
+		}
+	}
+
+	nextTurn, err := json.Marshal(sideName(!isComputer))
+	if err != nil {
+		return nil, err
+	}
+	payload["next_turn"] = nextTurn
+	return json.Marshal(payload)
+}
+
+// RegisterUpcaster adds upcaster moving payloads of event type
+// from given version to the next one
+func RegisterUpcaster(eventType string, fromVersion int, upcaster Upcaster) {
+	upcasters[upcasterKey{eventType, fromVersion}] = upcaster
+}
+
+// SchemaVersion returns current payload version of event type
+func SchemaVersion(eventType string) int {
+	if version, ok := schemaVersions[eventType]; ok {
+		return version
+	}
+	return 1
+}
+
+// Upcast brings stored event data to the current schema version of its
+// type. Events stored before versioning was introduced are version 1
+func Upcast(event *Event) error {
+	if event.SchemaVersion == 0 {
+		event.SchemaVersion = 1
+	}
+
+	currentVersion := SchemaVersion(event.EventType)
+	if event.SchemaVersion == currentVersion {
+		return nil
+	}
+	if event.SchemaVersion > currentVersion {
+		return fmt.Errorf("%s event has schema version %d newer than supported %d", event.EventType, event.SchemaVersion, currentVersion)
+	}
+
+	data, err := event.EncodeData()
+	if err != nil {
+		return err
+	}
+
+	payload := []byte(data)
+	for version := event.SchemaVersion; version < currentVersion; version++ {
+		upcaster, ok := upcasters[upcasterKey{event.EventType, version}]
+		if !ok {
+			return fmt.Errorf("no upcaster for %s event from schema version %d", event.EventType, version)
+		}
+
+		if payload, err = upcaster(payload); err != nil {
+			return fmt.Errorf("cannot upcast %s event from schema version %d: %v", event.EventType, version, err)
+		}
+	}
+
+	event.Data = string(payload)
+	event.SchemaVersion = currentVersion
+	return nil
+}
@@ -0,0 +1,246 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/billyboar/battleships/helpers"
+)
+
+// Special weapons, their ammo comes from RuleSet
+const (
+	WeaponSonar   = "sonar"   // counts intact ship cells in 3x3 area
+	WeaponTorpedo = "torpedo" // travels along a row until it hits
+	WeaponBomb    = "bomb"    // hits the cell and cells on its sides
+)
+
+// weaponEventTypes maps weapons to events recording their use
+var weaponEventTypes = map[string]string{
+	WeaponSonar:   SonarEventType,
+	WeaponTorpedo: TorpedoEventType,
+	WeaponBomb:    BombEventType,
+}
+
+// Weapon errors
+var (
+	ErrUnknownWeapon = errors.New("weapon is not available")
+	ErrNoAmmo        = errors.New("weapon is out of ammo")
+)
+
+// SonarScan is a reading of the sonar, kept on the scanned board
+type SonarScan struct {
+	Center    Cell `json:"center"`
+	ShipCells int  `json:"ship_cells"` // ship cells not hit yet at the time of scan
+}
+
+// Area returns cells covered by the scan
+func (s SonarScan) Area() []Cell {
+	return append([]Cell{s.Center}, s.Center.Neighbours()...)
+}
+
+// WeaponResult describes what special weapon did
+type WeaponResult struct {
+	Weapon    string        `json:"weapon"`
+	Shots     []Cell        `json:"shots,omitempty"` // cells fired at, is_dead marks hits
+	ShipCells int           `json:"ship_cells"`      // found by sonar
+	DeadShips []*BattleShip `json:"dead_ships,omitempty"`
+}
+
+// WeaponMove is use of special weapon. Torpedo is launched into
+// the row of the target from the left edge, or the right one
+type WeaponMove struct {
+	Weapon    string `json:"weapon"`
+	Target    Cell   `json:"target"`
+	FromRight bool   `json:"from_right,omitempty"`
+}
+
+// AmmoLeft returns how many times side can still use the weapon
+func (s *Session) AmmoLeft(weapon string, isComputer bool) int {
+	return s.Rules.Weapons[weapon] - s.WeaponsUsed[sideName(isComputer)][weapon]
+}
+
+// Ammo returns ammo left of every weapon of the side
+func (s *Session) Ammo(isComputer bool) map[string]int {
+	ammo := map[string]int{}
+	for weapon := range s.Rules.Weapons {
+		ammo[weapon] = s.AmmoLeft(weapon, isComputer)
+	}
+	return ammo
+}
+
+// ValidateWeapon checks if side may use the weapon
+func (s *Session) ValidateWeapon(move WeaponMove, isComputer bool) error {
+	if err := s.validateMove(isComputer); err != nil {
+		return err
+	}
+	if _, ok := s.Rules.Weapons[move.Weapon]; !ok {
+		return ErrUnknownWeapon
+	}
+	if s.AmmoLeft(move.Weapon, isComputer) <= 0 {
+		return ErrNoAmmo
+	}
+
+	target := s.targetBoard(isComputer)
+	if !target.Contains(move.Target) {
+		return ErrCellOutOfBoard
+	}
+	if move.Weapon != WeaponTorpedo && target.IsTerrain(move.Target) {
+		return ErrTerrainCell
+	}
+	if move.Weapon != WeaponSonar && len(target.weaponShots(move)) == 0 {
+		return ErrAlreadyShot
+	}
+	return nil
+}
+
+// UseWeapon validates and fires the weapon and returns event recording
+// it. Ships sunk by the weapon are marked dead
+func (s *Session) UseWeapon(move WeaponMove, isComputer bool) (*WeaponResult, *Event, error) {
+	if err := s.ValidateWeapon(move, isComputer); err != nil {
+		return nil, nil, err
+	}
+
+	result, hitShips := s.fireWeapon(move, isComputer)
+
+	target := s.targetBoard(isComputer)
+	for _, shipID := range hitShips {
+		if deadShip := target.MarkShipIfDead(shipID); deadShip != nil {
+			result.DeadShips = append(result.DeadShips, deadShip)
+		}
+	}
+
+	event := newEvent(s.ID, weaponEventTypes[move.Weapon], WeaponEventData{
+		WeaponMove: move,
+		IsComputer: isComputer,
+	})
+	return result, event, nil
+}
+
+// fireWeapon applies weapon effects, uses its ammo and passes the turn
+func (s *Session) fireWeapon(move WeaponMove, isComputer bool) (result *WeaponResult, hitShips []string) {
+	target := s.targetBoard(isComputer)
+	result = &WeaponResult{Weapon: move.Weapon}
+
+	if move.Weapon == WeaponSonar {
+		scan := SonarScan{Center: move.Target}
+		for _, cell := range scan.Area() {
+			if shipCell := target.shipCellAt(cell); shipCell != nil && !shipCell.IsDead {
+				scan.ShipCells++
+			}
+		}
+		target.SonarScans = append(target.SonarScans, scan)
+		result.ShipCells = scan.ShipCells
+	}
+
+	seen := map[string]bool{}
+	for _, shot := range target.weaponShots(move) {
+		isHit, shipID := target.RegisterShot(shot)
+		result.Shots = append(result.Shots, Cell{X: shot.X, Y: shot.Y, IsDead: isHit})
+
+		if isHit && !seen[shipID] {
+			seen[shipID] = true
+			hitShips = append(hitShips, shipID)
+		}
+	}
+
+	side := sideName(isComputer)
+	if s.WeaponsUsed == nil {
+		s.WeaponsUsed = map[string]map[string]int{}
+	}
+	if s.WeaponsUsed[side] == nil {
+		s.WeaponsUsed[side] = map[string]int{}
+	}
+	s.WeaponsUsed[side][move.Weapon]++
+
+	s.passTurn(isComputer)
+	return result, hitShips
+}
+
+// weaponShots returns cells the weapon fires at, cells which were
+// already shot are skipped
+func (b *Board) weaponShots(move WeaponMove) []Cell {
+	var shots []Cell
+	switch move.Weapon {
+	case WeaponBomb:
+		for _, cell := range append([]Cell{move.Target}, move.Target.Sides()...) {
+			if b.Contains(cell) && !b.IsTerrain(cell) && !b.HasShot(cell) {
+				shots = append(shots, cell)
+			}
+		}
+	case WeaponTorpedo:
+		// torpedo passes over wrecks and water fired at before, and
+		// stops at terrain or the first ship it hits
+		for i := 0; i < b.Width; i++ {
+			cell := Cell{X: i, Y: move.Target.Y}
+			if move.FromRight {
+				cell.X = b.Width - 1 - i
+			}
+			if b.IsTerrain(cell) {
+				break
+			}
+			if b.HasShot(cell) {
+				continue
+			}
+
+			shots = append(shots, cell)
+			if b.shipCellAt(cell) != nil {
+				break
+			}
+		}
+	}
+	return shots
+}
+
+// PlanComputerWeapon decides if computer uses special weapon in its
+// move, nil means plain shot. Weapons are only used while no wounded
+// ship is left to finish
+func (s *Session) PlanComputerWeapon() *WeaponMove {
+	if s.Player.hasTargets() {
+		return nil
+	}
+
+	var available []string
+	for _, weapon := range []string{WeaponSonar, WeaponBomb, WeaponTorpedo} {
+		if s.AmmoLeft(weapon, true) > 0 {
+			available = append(available, weapon)
+		}
+	}
+	// weapons are saved for later in most of the moves
+	if len(available) == 0 || helpers.GenerateRandomInt(3) != 0 {
+		return nil
+	}
+
+	target := s.ComputerShot()
+	if target == nil {
+		return nil
+	}
+	move := &WeaponMove{
+		Weapon:    available[helpers.GenerateRandomInt(len(available))],
+		Target:    *target,
+		FromRight: helpers.GenerateRandomInt(2) == 0,
+	}
+	if s.ValidateWeapon(*move, true) != nil {
+		return nil
+	}
+	return move
+}
+
+// ApplyWeaponEvent applies use of special weapon
+func (s *Session) ApplyWeaponEvent(event *Event) error {
+	var payload WeaponEventData
+	if err := decodeEventData(event, &payload); err != nil {
+		return err
+	}
+	if s.Status.IsFinished() {
+		return ErrGameOver
+	}
+	if weaponEventTypes[payload.Weapon] != event.EventType {
+		return fmt.Errorf("%s event cannot use %q", event.EventType, payload.Weapon)
+	}
+	if !s.targetBoard(payload.IsComputer).Contains(payload.Target) {
+		return fmt.Errorf("%s at (%d, %d) is outside of the board", payload.Weapon, payload.Target.X, payload.Target.Y)
+	}
+
+	s.fireWeapon(payload.WeaponMove, payload.IsComputer)
+	return nil
+}
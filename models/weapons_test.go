@@ -0,0 +1,71 @@
+package models
+
+import "testing"
+
+func TestWeapons(t *testing.T) {
+	rules, _ := RuleSetByName("arsenal")
+	session, err := NewSessionWithRules(rules)
+	if err != nil {
+		t.Fatal("cannot create session:", err)
+	}
+
+	// destroyers at (2, 3) and (3, 3), and at (7, 8) and (8, 8)
+	session.Computer.Battleships = nil
+	for _, head := range []Cell{{X: 2, Y: 3}, {X: 7, Y: 8}} {
+		destroyer, _ := NewBattleShip("destroyer", 2)
+		destroyer.IsVertical = false
+		destroyer.BuildBody(head)
+		session.Computer.Battleships = append(session.Computer.Battleships, destroyer)
+	}
+
+	created := CreateNewSessionEvent(session)
+	created.Data, _ = created.EncodeData()
+	events := []*Event{created}
+
+	use := func(move WeaponMove) *WeaponResult {
+		session.Turn = SidePlayer
+		result, event, err := session.UseWeapon(move, false)
+		if err != nil {
+			t.Fatalf("cannot use %s: %v", move.Weapon, err)
+		}
+		events = append(events, event)
+		return result
+	}
+
+	result := use(WeaponMove{Weapon: WeaponSonar, Target: Cell{X: 1, Y: 2}})
+	if result.ShipCells != 1 || len(session.Computer.Shots) != 0 {
+		t.Errorf("expected sonar to find 1 ship cell without shooting, got %d cells and %d shots", result.ShipCells, len(session.Computer.Shots))
+	}
+	if session.Turn != SideComputer {
+		t.Errorf("expected weapon to pass the turn, got %s", session.Turn)
+	}
+
+	result = use(WeaponMove{Weapon: WeaponTorpedo, Target: Cell{X: 9, Y: 3}})
+	if len(result.Shots) != 3 || !result.Shots[2].IsDead || result.Shots[2].X != 2 {
+		t.Errorf("expected torpedo to stop at the ship, got %v", result.Shots)
+	}
+
+	result = use(WeaponMove{Weapon: WeaponBomb, Target: Cell{X: 3, Y: 3}})
+	if len(result.Shots) != 4 || len(result.DeadShips) != 1 {
+		t.Errorf("expected bomb to skip shot cell and sink the ship, got %v", result.Shots)
+	}
+
+	session.Turn = SidePlayer
+	if _, _, err := session.UseWeapon(WeaponMove{Weapon: WeaponTorpedo, Target: Cell{Y: 5}}, false); err != ErrNoAmmo {
+		t.Errorf("expected %v, got %v", ErrNoAmmo, err)
+	}
+	if ammo := session.Ammo(false); ammo[WeaponSonar] != 1 || ammo[WeaponTorpedo] != 0 || ammo[WeaponBomb] != 1 {
+		t.Errorf("unexpected ammo left %v", ammo)
+	}
+
+	replayed, err := BuildSessionEvents(events, session.ID, true)
+	if err != nil {
+		t.Fatal("cannot replay weapons:", err)
+	}
+	if len(replayed.Computer.Shots) != len(session.Computer.Shots) || len(replayed.Computer.SonarScans) != 1 {
+		t.Errorf("expected replayed board to match, got %d shots and %d scans", len(replayed.Computer.Shots), len(replayed.Computer.SonarScans))
+	}
+	if replayed.AmmoLeft(WeaponBomb, false) != 1 {
+		t.Errorf("expected replayed session to keep ammo used")
+	}
+}